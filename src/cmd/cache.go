@@ -8,7 +8,9 @@ var CacheCmd = &cobra.Command{
 	Use:   "cache",
 	Short: "Manipulates the packages cache",
 	Long: "Provides subcommands to manipulate the packages cache. The cache is a" +
-		" directory where packages are downloaded and stored for later use.",
+		" directory where packages are downloaded and stored for later use. It keeps" +
+		" a clone of each package's repository, along with a read-only copy of the" +
+		" files at each commit which has been installed.",
 }
 
 func init() {
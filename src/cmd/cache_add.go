@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	utils "ahkpm/src/utils"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var CacheAddCmd = &cobra.Command{
+	Use:   "add <packageName>[@<version>]...",
+	Short: "Downloads packages into the cache without installing them",
+	Long: "Downloads packages into the cache without installing them, so that they can later be" +
+		" installed with --offline. If no version is given, the latest version is downloaded.",
+	Example: "ahkpm cache add gh:joshuacc/fake-package@1.0.0",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pr := core.NewPackagesRepository()
+		for _, arg := range args {
+			dep, err := core.DependencyFromSpecifier(arg)
+			if err != nil {
+				utils.Exit(err.Error())
+			}
+
+			sha, err := pr.AddToCache(dep)
+			if err != nil {
+				utils.Exit(err.Error())
+			}
+			fmt.Printf("Cached %s@%s (%s)\n", dep.Name(), dep.Version().String(), core.ShortSHA(sha))
+		}
+	},
+}
+
+func init() {
+	CacheCmd.AddCommand(CacheAddCmd)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var CacheDirCmd = &cobra.Command{
+	Use:   "dir",
+	Short: "Prints the location of the cache",
+	Long:  "Prints the location of the directory where packages are downloaded and stored.",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(core.NewPackagesRepository().GetCacheDir())
+	},
+}
+
+func init() {
+	CacheCmd.AddCommand(CacheDirCmd)
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"ahkpm/src/invariant"
+	utils "ahkpm/src/utils"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var CacheGcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Evicts the least recently used packages from the cache",
+	Long: "Evicts the least recently used packages from the cache until it is within the maxCacheSizeMB" +
+		" and maxAgeDays limits in config.json, or the limits given by flags. Packages in the current" +
+		" directory's ahkpm.lock are never evicted. When limits are set in config.json, this also runs" +
+		" automatically after install, update and ci.",
+	Example: "ahkpm cache gc --max-size-mb 500 --max-age-days 30",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.GetConfig()
+		if err != nil {
+			utils.Exit(err.Error())
+		}
+		limits := config.CacheLimits()
+		if cmd.Flags().Changed("max-size-mb") {
+			maxSizeMB, err := cmd.Flags().GetInt("max-size-mb")
+			invariant.AssertNoError(err)
+			limits.MaxSize = int64(maxSizeMB) * 1024 * 1024
+		}
+		if cmd.Flags().Changed("max-age-days") {
+			maxAgeDays, err := cmd.Flags().GetInt("max-age-days")
+			invariant.AssertNoError(err)
+			limits.MaxAge = time.Duration(maxAgeDays) * 24 * time.Hour
+		}
+		if limits.IsEmpty() {
+			utils.Exit("No cache limits are set. Set maxCacheSizeMB or maxAgeDays in " +
+				core.GetConfigPath() + ", or pass --max-size-mb or --max-age-days.")
+		}
+
+		protected, err := getProtectedDependencies()
+		if err != nil {
+			utils.Exit(err.Error())
+		}
+
+		pr := core.NewPackagesRepository()
+		var evictions []core.CacheEviction
+		if cmd.Flag("dry-run").Value.String() == "true" {
+			cachedPackages, err := pr.ListCache()
+			if err != nil {
+				utils.Exit(err.Error())
+			}
+			evictions = core.SelectCacheEvictions(cachedPackages, protected, limits, time.Now())
+		} else {
+			evictions, err = pr.CollectCacheGarbage(limits, protected)
+			if err != nil {
+				utils.Exit(err.Error())
+			}
+		}
+
+		if len(evictions) == 0 {
+			fmt.Println("The cache is already within its limits")
+			return
+		}
+
+		verb := "Removed"
+		if cmd.Flag("dry-run").Value.String() == "true" {
+			verb = "Would remove"
+		}
+		var freed int64
+		for _, eviction := range evictions {
+			fmt.Printf("%s %s (%s, last used %s)\n",
+				verb,
+				getCacheEvictionName(eviction),
+				FormatSize(eviction.Size),
+				eviction.LastUsed.Local().Format("2006-01-02 15:04"),
+			)
+			freed += eviction.Size
+		}
+		fmt.Printf("%s %s in total\n", verb, FormatSize(freed))
+	},
+}
+
+func init() {
+	CacheGcCmd.Flags().Int("max-size-mb", 0, "Evict packages until the cache uses at most this many megabytes")
+	CacheGcCmd.Flags().Int("max-age-days", 0, "Evict packages which have not been used for this many days")
+	CacheGcCmd.Flags().Bool("dry-run", false, "List what would be evicted without removing anything")
+	CacheCmd.AddCommand(CacheGcCmd)
+}
+
+// collectCacheGarbageAutomatically keeps the cache within the limits in
+// config.json, if there are any. It is run after commands which download
+// packages. Failures are reported but do not fail the command.
+func collectCacheGarbageAutomatically(cmd *cobra.Command, args []string) {
+	config, err := core.GetConfig()
+	if err != nil {
+		fmt.Println("Skipping cache cleanup. " + err.Error())
+		return
+	}
+	limits := config.CacheLimits()
+	if limits.IsEmpty() {
+		return
+	}
+
+	protected, err := getProtectedDependencies()
+	if err != nil {
+		fmt.Println("Skipping cache cleanup. " + err.Error())
+		return
+	}
+
+	evictions, err := core.NewPackagesRepository().CollectCacheGarbage(limits, protected)
+	if err != nil {
+		fmt.Println("Unable to clean up the cache. " + err.Error())
+		return
+	}
+	if len(evictions) == 0 {
+		return
+	}
+
+	var freed int64
+	for _, eviction := range evictions {
+		freed += eviction.Size
+	}
+	fmt.Println("Freed " + FormatSize(freed) + " by evicting unused packages from the cache")
+}
+
+// getProtectedDependencies returns the packages in the current directory's
+// ahkpm.lock, which must never be evicted from the cache
+func getProtectedDependencies() ([]core.ResolvedDependency, error) {
+	lm, err := core.LockManifestFromCwd()
+	if errors.Is(err, fs.ErrNotExist) {
+		return []core.ResolvedDependency{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lm.Resolved, nil
+}
+
+func getCacheEvictionName(eviction core.CacheEviction) string {
+	if eviction.SHA == "" {
+		return eviction.Name
+	}
+	return eviction.Name + "@" + core.ShortSHA(eviction.SHA)
+}
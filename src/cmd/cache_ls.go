@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"ahkpm/src/invariant"
+	utils "ahkpm/src/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// maxListedTags is the number of tags shown for each package by `cache ls`
+const maxListedTags = 5
+
+var CacheLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "Lists the packages in the cache",
+	Long: "Lists each package in the cache along with the disk space it uses, when it was last" +
+		" used, and its most recent tags. Use --json to include every tag and snapshot.",
+	Run: func(cmd *cobra.Command, args []string) {
+		cachedPackages, err := core.NewPackagesRepository().ListCache()
+		if err != nil {
+			utils.Exit(err.Error())
+		}
+
+		if cmd.Flag("json").Value.String() == "true" {
+			jsonBytes, err := json.MarshalIndent(cachedPackages, "", "  ")
+			invariant.AssertNoError(err)
+			fmt.Println(string(jsonBytes))
+			return
+		}
+
+		if len(cachedPackages) == 0 {
+			fmt.Println("The cache is empty")
+			return
+		}
+		fmt.Print(GetCachedPackagesForDisplay(cachedPackages))
+	},
+}
+
+func init() {
+	CacheLsCmd.Flags().Bool("json", false, "Output the cached packages as JSON")
+	CacheCmd.AddCommand(CacheLsCmd)
+}
+
+func GetCachedPackagesForDisplay(cachedPackages []core.CachedPackage) string {
+	headers := []string{"Name", "Size", "Last used", "Tags"}
+	rows := make([][]string, len(cachedPackages))
+	for i, cachedPackage := range cachedPackages {
+		tags := cachedPackage.Tags
+		if len(tags) > maxListedTags {
+			tags = append(tags[:maxListedTags:maxListedTags], fmt.Sprintf("(%d more)", len(cachedPackage.Tags)-maxListedTags))
+		}
+		rows[i] = []string{
+			cachedPackage.Name,
+			FormatSize(cachedPackage.Size),
+			cachedPackage.LastUsed.Local().Format("2006-01-02 15:04"),
+			strings.Join(tags, ", "),
+		}
+	}
+	return formatTable(headers, rows)
+}
+
+// FormatSize formats a number of bytes for display, such as "1.5 MB"
+func FormatSize(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
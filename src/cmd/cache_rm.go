@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	utils "ahkpm/src/utils"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var CacheRmCmd = &cobra.Command{
+	Use:     "rm <packageName>...",
+	Aliases: []string{"remove"},
+	Short:   "Removes packages from the cache",
+	Long:    "Removes packages from the cache, along with every version of their files. They are downloaded again when next needed.",
+	Example: "ahkpm cache rm gh:joshuacc/fake-package",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pr := core.NewPackagesRepository()
+		for _, arg := range args {
+			name := core.CanonicalizeDependencyName(arg)
+			err := pr.RemoveFromCache(name)
+			if err != nil {
+				utils.Exit(err.Error())
+			}
+			fmt.Println("Removed " + name)
+		}
+	},
+}
+
+func init() {
+	CacheCmd.AddCommand(CacheRmCmd)
+}
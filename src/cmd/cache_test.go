@@ -1 +1,33 @@
-package cmd
+package cmd_test
+
+import (
+	. "ahkpm/src/cmd"
+	. "ahkpm/src/core"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCachedPackagesForDisplay(t *testing.T) {
+	lastUsed := time.Date(2022, 10, 1, 12, 30, 0, 0, time.Local)
+	cachedPackages := []CachedPackage{
+		{Name: "github.com/a/a", Size: 2048, LastUsed: lastUsed, Tags: []string{"2.0.0", "1.1.0", "1.0.0"}},
+		{Name: "github.com/b/b", Size: 10, LastUsed: lastUsed, Tags: []string{"6", "5", "4", "3", "2", "1", "0"}},
+	}
+
+	expected := "Name          \tSize  \tLast used       \tTags                   \n"
+	expected += "--------------\t------\t----------------\t-----------------------\n"
+	expected += "github.com/a/a\t2.0 KB\t2022-10-01 12:30\t2.0.0, 1.1.0, 1.0.0    \n"
+	expected += "github.com/b/b\t10 B  \t2022-10-01 12:30\t6, 5, 4, 3, 2, (2 more)\n"
+
+	assert.Equal(t, expected, GetCachedPackagesForDisplay(cachedPackages))
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "0 B", FormatSize(0))
+	assert.Equal(t, "1023 B", FormatSize(1023))
+	assert.Equal(t, "1.5 KB", FormatSize(1536))
+	assert.Equal(t, "3.0 MB", FormatSize(3*1024*1024))
+	assert.Equal(t, "2048.0 GB", FormatSize(2*1024*1024*1024*1024))
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	utils "ahkpm/src/utils"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var CacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Checks the cache for corruption and repairs it",
+	Long: "Checks that every commit, tree and file reachable from the branches and tags of each cached" +
+		" repository can be read, and that the cached files of each commit have not been modified." +
+		" Corrupt repositories are downloaded again, and modified files are removed so that they are" +
+		" extracted again when next needed.",
+	Run: func(cmd *cobra.Command, args []string) {
+		issues, err := core.NewPackagesRepository().VerifyCache()
+		if err != nil {
+			utils.Exit(err.Error())
+		}
+
+		if len(issues) == 0 {
+			fmt.Println("No problems found in the cache")
+			return
+		}
+
+		hasUnfixedIssue := false
+		for _, issue := range issues {
+			if issue.Fixed {
+				fmt.Println("Fixed " + issue.Name + ": " + issue.Problem)
+			} else {
+				fmt.Println("Unable to fix " + issue.Name + ": " + issue.Problem)
+				hasUnfixedIssue = true
+			}
+		}
+		if hasUnfixedIssue {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	CacheCmd.AddCommand(CacheVerifyCmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"ahkpm/src/core"
+	"ahkpm/src/invariant"
+	"ahkpm/src/utils"
+	_ "embed"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed ci-long.md
+var ciLong string
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Installs exactly what is in ahkpm.lock, failing if it does not match ahkpm.json",
+	Long:  ciLong,
+	Run: func(cmd *cobra.Command, args []string) {
+		ahkpmFileExists, err := utils.FileExists(`ahkpm.json`)
+		invariant.AssertNoError(err)
+
+		if !ahkpmFileExists {
+			utils.Exit("ahkpm.json not found in current directory. Run `ahkpm init` to create one.")
+		}
+
+		err = core.Installer{}.InstallFrozen()
+		if err != nil {
+			utils.Exit(err.Error())
+		}
+	},
+	PostRun: collectCacheGarbageAutomatically,
+}
+
+func init() {
+	RootCmd.AddCommand(ciCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"ahkpm/src/utils"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed dedupe-long.md
+var dedupeLong string
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Combines duplicate copies of packages in ahkpm-modules",
+	Long:  dedupeLong,
+	Run: func(cmd *cobra.Command, args []string) {
+		lm := getLockManifestOrExit()
+
+		installer := core.Installer{}
+		dedupedPackages, err := installer.Dedupe(*lm)
+		if err != nil {
+			utils.Exit(err.Error())
+		}
+
+		if len(dedupedPackages) == 0 {
+			fmt.Println("No duplicate packages found")
+			return
+		}
+
+		for _, dedupedPackage := range dedupedPackages {
+			fmt.Printf(
+				"Deduped %s@%s from %d copies to %s\n",
+				dedupedPackage.Name,
+				dedupedPackage.Version,
+				len(dedupedPackage.FromPaths),
+				strings.Join(dedupedPackage.ToPaths, " and "),
+			)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dedupeCmd)
+}
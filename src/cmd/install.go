@@ -33,7 +33,21 @@ var installCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		installer := core.Installer{}
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		invariant.AssertNoError(err)
+
+		installer := core.Installer{Concurrency: concurrency}
+
+		if cmd.Flag("frozen-lockfile").Value.String() == "true" {
+			if len(args) > 0 {
+				utils.Exit("Cannot add dependencies with --frozen-lockfile")
+			}
+			err := installer.InstallFrozen()
+			if err != nil {
+				utils.Exit(err.Error())
+			}
+			return
+		}
 
 		newDeps, err := core.NewDependencySet().AddDependenciesFromSpecifiers(args)
 		if err != nil {
@@ -49,8 +63,11 @@ var installCmd = &cobra.Command{
 
 		installer.Install(newDeps)
 	},
+	PostRun: collectCacheGarbageAutomatically,
 }
 
 func init() {
+	installCmd.Flags().Bool("frozen-lockfile", false, "Install exactly what is in ahkpm.lock, failing if it does not match ahkpm.json")
+	installCmd.Flags().Int("concurrency", core.DefaultConcurrency, "Maximum number of packages to fetch at the same time")
 	RootCmd.AddCommand(installCmd)
 }
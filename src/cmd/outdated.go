@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"ahkpm/src/invariant"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+)
+
+//go:embed outdated-long.md
+var outdatedLong string
+
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "Lists dependencies which have newer versions available",
+	Long:  outdatedLong,
+	Run: func(cmd *cobra.Command, args []string) {
+		lm := getLockManifestOrExit()
+
+		outdated := core.GetOutdatedDependencies(core.NewPackagesRepository(), *lm)
+
+		if cmd.Flag("json").Value.String() == "true" {
+			jsonBytes, err := json.MarshalIndent(outdated, "", "  ")
+			invariant.AssertNoError(err)
+			fmt.Println(string(jsonBytes))
+		} else if len(outdated) == 0 {
+			fmt.Println("All dependencies are up to date")
+		} else {
+			fmt.Print(GetOutdatedForDisplay(outdated))
+		}
+
+		// Exit with an error code so that CI fails when anything is outdated
+		// or could not be checked
+		if len(outdated) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	outdatedCmd.Flags().Bool("json", false, "Output the outdated dependencies as JSON")
+	RootCmd.AddCommand(outdatedCmd)
+}
+
+// GetOutdatedForDisplay lays out the outdated dependencies in a table. An
+// Error column is added if any of them could not be checked.
+func GetOutdatedForDisplay(outdated []core.OutdatedDependency) string {
+	hasErrors := slices.IndexFunc(outdated, func(od core.OutdatedDependency) bool {
+		return od.Error != ""
+	}) >= 0
+
+	headers := []string{"Name", "Requested", "Current", "Wanted", "Latest"}
+	if hasErrors {
+		headers = append(headers, "Error")
+	}
+	rows := make([][]string, len(outdated))
+	for i, od := range outdated {
+		rows[i] = []string{od.Name, od.Requested, od.Current, od.Wanted, od.Latest}
+		if hasErrors {
+			rows[i] = append(rows[i], od.Error)
+		}
+	}
+
+	return formatTable(headers, rows)
+}
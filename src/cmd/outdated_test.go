@@ -0,0 +1,37 @@
+package cmd_test
+
+import (
+	. "ahkpm/src/cmd"
+	. "ahkpm/src/core"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOutdatedForDisplay(t *testing.T) {
+	outdated := []OutdatedDependency{
+		{Name: "github.com/a/a", Requested: "^1.0.0", Current: "1.0.0", Wanted: "1.1.0", Latest: "2.0.0"},
+		{Name: "github.com/c/c", Requested: "branch:main", Current: "ccccccc", Wanted: "eeeeeee", Latest: "eeeeeee"},
+	}
+
+	expected := "Name          \tRequested  \tCurrent\tWanted \tLatest \n"
+	expected += "--------------\t-----------\t-------\t-------\t-------\n"
+	expected += "github.com/a/a\t^1.0.0     \t1.0.0  \t1.1.0  \t2.0.0  \n"
+	expected += "github.com/c/c\tbranch:main\tccccccc\teeeeeee\teeeeeee\n"
+
+	assert.Equal(t, expected, GetOutdatedForDisplay(outdated))
+}
+
+func TestGetOutdatedForDisplayWithErrors(t *testing.T) {
+	outdated := []OutdatedDependency{
+		{Name: "github.com/a/a", Requested: "^1.0.0", Current: "1.0.0", Wanted: "1.1.0", Latest: "2.0.0"},
+		{Name: "github.com/b/b", Requested: "^3.0.0", Current: "bbbbbbb", Wanted: "n/a", Latest: "2.0.0", Error: "No version matches ^3.0.0"},
+	}
+
+	expected := "Name          \tRequested\tCurrent\tWanted\tLatest\tError                    \n"
+	expected += "--------------\t---------\t-------\t------\t------\t-------------------------\n"
+	expected += "github.com/a/a\t^1.0.0   \t1.0.0  \t1.1.0 \t2.0.0 \t                         \n"
+	expected += "github.com/b/b\t^3.0.0   \tbbbbbbb\tn/a   \t2.0.0 \tNo version matches ^3.0.0\n"
+
+	assert.Equal(t, expected, GetOutdatedForDisplay(outdated))
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"ahkpm/src/utils"
+	_ "embed"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed prune-long.md
+var pruneLong string
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Removes packages from ahkpm-modules which are not in ahkpm.lock",
+	Long:  pruneLong,
+	Run: func(cmd *cobra.Command, args []string) {
+		lm := getLockManifestOrExit()
+		dryRun := cmd.Flag("dry-run").Value.String() == "true"
+
+		prunedPaths, err := core.Prune(*lm, dryRun)
+		if err != nil {
+			utils.Exit(err.Error())
+		}
+
+		if len(prunedPaths) == 0 {
+			fmt.Println("No extraneous packages found")
+			return
+		}
+
+		for _, prunedPath := range prunedPaths {
+			if dryRun {
+				fmt.Println("Would remove " + prunedPath)
+			} else {
+				fmt.Println("Removed " + prunedPath)
+			}
+		}
+	},
+}
+
+func init() {
+	pruneCmd.Flags().Bool("dry-run", false, "List the packages which would be removed without removing them")
+	RootCmd.AddCommand(pruneCmd)
+}
@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"ahkpm/src/constants"
+	"ahkpm/src/core"
 	utils "ahkpm/src/utils"
 	_ "embed"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -17,6 +20,10 @@ var RootCmd = &cobra.Command{
 	Use:   "ahkpm",
 	Short: "The root command for the ahkpm CLI",
 	Long:  rootLong,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// The offline setting in config.json is read when it is first needed
+		core.SetOffline(cmd.Flag("offline").Value.String() == "true")
+	},
 	CompletionOptions: cobra.CompletionOptions{
 		DisableDefaultCmd: true,
 	},
@@ -42,6 +49,7 @@ var RootCmd = &cobra.Command{
 func init() {
 	RootCmd.Flags().BoolP("version", "v", false, "Display the version of ahkpm")
 	RootCmd.Flags().BoolP("ahk-version", "a", false, "Display the version of AutoHotkey")
+	RootCmd.PersistentFlags().Bool("offline", false, "Resolve packages only from the cache, without accessing the network")
 }
 
 func Execute() {
@@ -58,3 +66,16 @@ func GetAhkVersion() string {
 	}
 	return ahkVersion
 }
+
+// getLockManifestOrExit reads ahkpm.lock from the current directory, exiting
+// with a helpful message if it is missing or cannot be read
+func getLockManifestOrExit() *core.LockManifest {
+	lm, err := core.LockManifestFromCwd()
+	if errors.Is(err, fs.ErrNotExist) {
+		utils.Exit("ahkpm.lock not found in current directory. Run `ahkpm install` to create one.")
+	}
+	if err != nil {
+		utils.Exit(err.Error())
+	}
+	return lm
+}
@@ -1 +1,27 @@
 package cmd_test
+
+import (
+	. "ahkpm/src/cmd"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionDoesNotNeedConfig(t *testing.T) {
+	profile := t.TempDir()
+	t.Setenv("userprofile", profile)
+	// Paths in the ahkpm directory use backslashes, which are part of the
+	// file name on other platforms
+	assert.NoError(t, os.WriteFile(profile+`\.ahkpm\config.json`, []byte(`{"offline": `), 0644))
+
+	RootCmd.SetArgs([]string{"--version"})
+	assert.NoError(t, RootCmd.Execute())
+
+	RootCmd.SetArgs([]string{"cache", "dir"})
+	assert.NoError(t, RootCmd.Execute())
+
+	os.Unsetenv("userprofile")
+	RootCmd.SetArgs([]string{"--version"})
+	assert.NoError(t, RootCmd.Execute())
+}
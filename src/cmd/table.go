@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"ahkpm/src/utils"
+	"strings"
+)
+
+// formatTable lays out the rows in columns under the headers, separated by
+// tabs and padded so that they line up
+func formatTable(headers []string, rows [][]string) string {
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = len(header)
+		for _, row := range rows {
+			if len(row[i]) > widths[i] {
+				widths[i] = len(row[i])
+			}
+		}
+	}
+
+	var table strings.Builder
+	writeRow := func(cells []string) {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			padded[i] = utils.RightPad(cell, " ", widths[i])
+		}
+		table.WriteString(strings.Join(padded, "\t") + "\n")
+	}
+
+	writeRow(headers)
+	underlines := make([]string, len(headers))
+	for i := range headers {
+		underlines[i] = strings.Repeat("-", widths[i])
+	}
+	writeRow(underlines)
+	for _, row := range rows {
+		writeRow(row)
+	}
+
+	return table.String()
+}
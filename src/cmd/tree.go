@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"ahkpm/src/invariant"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed tree-long.md
+var treeLong string
+
+var treeCmd = &cobra.Command{
+	Use:     "tree [<package>]",
+	Short:   "Displays the tree of installed dependencies, including transitive ones",
+	Long:    treeLong,
+	Example: "ahkpm tree\nahkpm tree --depth 0\nahkpm tree gh:joshuacc/fake-package",
+	Run: func(cmd *cobra.Command, args []string) {
+		lm := getLockManifestOrExit()
+
+		tree := core.ResolvedDependencyTreeFromArray(lm.Resolved)
+		if len(args) > 0 {
+			tree = tree.FilterByName(core.CanonicalizeDependencyName(args[0]))
+		}
+
+		depth, err := cmd.Flags().GetInt("depth")
+		invariant.AssertNoError(err)
+
+		if cmd.Flag("json").Value.String() == "true" {
+			jsonBytes, err := json.MarshalIndent(GetTreeForJson(tree, depth), "", "  ")
+			invariant.AssertNoError(err)
+			fmt.Println(string(jsonBytes))
+			return
+		}
+
+		fmt.Print(GetTreeForDisplay(tree, depth))
+	},
+}
+
+func init() {
+	treeCmd.Flags().Int("depth", -1, "Maximum depth of transitive dependencies to display. 0 shows only top-level dependencies, -1 shows all.")
+	treeCmd.Flags().Bool("json", false, "Output the tree as JSON")
+	RootCmd.AddCommand(treeCmd)
+}
+
+type TreeJsonNode struct {
+	Name         string         `json:"name"`
+	Version      string         `json:"version"`
+	SHA          string         `json:"sha"`
+	Dependencies []TreeJsonNode `json:"dependencies"`
+}
+
+// GetTreeForJson converts the tree into a structure suitable for JSON output,
+// omitting anything nested more deeply than maxDepth. A negative maxDepth
+// means there is no limit.
+func GetTreeForJson(tree core.ResolvedDependencyTree, maxDepth int) []TreeJsonNode {
+	nodes := make([]TreeJsonNode, 0, len(tree))
+	for _, depNode := range tree {
+		children := make([]TreeJsonNode, 0)
+		if maxDepth != 0 {
+			children = GetTreeForJson(depNode.Children, maxDepth-1)
+		}
+		nodes = append(nodes, TreeJsonNode{
+			Name:         depNode.Value.Name,
+			Version:      depNode.Value.Version,
+			SHA:          depNode.Value.SHA,
+			Dependencies: children,
+		})
+	}
+	return nodes
+}
+
+// GetTreeForDisplay renders the tree as ASCII art, omitting anything nested
+// more deeply than maxDepth. A negative maxDepth means there is no limit.
+func GetTreeForDisplay(tree core.ResolvedDependencyTree, maxDepth int) string {
+	if len(tree) == 0 {
+		return "No dependencies found\n"
+	}
+
+	var output strings.Builder
+	for _, depNode := range tree {
+		output.WriteString(formatTreeNode(depNode.Value) + "\n")
+		if maxDepth != 0 {
+			writeTreeChildren(&output, depNode.Children, "", maxDepth-1)
+		}
+	}
+	return output.String()
+}
+
+func writeTreeChildren(output *strings.Builder, children []core.TreeNode[core.ResolvedDependency], prefix string, maxDepth int) {
+	for i, child := range children {
+		isLast := i == len(children)-1
+
+		branch, childPrefix := "├── ", "│   "
+		if isLast {
+			branch, childPrefix = "└── ", "    "
+		}
+
+		output.WriteString(prefix + branch + formatTreeNode(child.Value) + "\n")
+		if maxDepth != 0 {
+			writeTreeChildren(output, child.Children, prefix+childPrefix, maxDepth-1)
+		}
+	}
+}
+
+func formatTreeNode(dep core.ResolvedDependency) string {
+	return dep.Name + "@" + dep.Version + " (" + core.ShortSHA(dep.SHA) + ")"
+}
@@ -0,0 +1,72 @@
+package cmd_test
+
+import (
+	. "ahkpm/src/cmd"
+	. "ahkpm/src/core"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestTree() ResolvedDependencyTree {
+	return ResolvedDependencyTreeFromArray([]ResolvedDependency{
+		{
+			Name:        "github.com/a/a",
+			Version:     "^1.0.0",
+			SHA:         "aaaaaaaaaaaa",
+			InstallPath: "ahkpm-modules/github.com/a/a",
+		},
+		{
+			Name:        "github.com/aa/aa",
+			Version:     "branch:main",
+			SHA:         "bbbbbbbbbbbb",
+			InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/aa/aa",
+		},
+		{
+			Name:        "github.com/aaa/aaa",
+			Version:     "1.2.3",
+			SHA:         "cccccccccccc",
+			InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/aa/aa/ahkpm-modules/github.com/aaa/aaa",
+		},
+		{
+			Name:        "github.com/ab/ab",
+			Version:     "2.x.x",
+			SHA:         "dddddddddddd",
+			InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/ab/ab",
+		},
+		{
+			Name:        "github.com/b/b",
+			Version:     "tag:beta",
+			SHA:         "eeeeeeeeeeee",
+			InstallPath: "ahkpm-modules/github.com/b/b",
+		},
+	})
+}
+
+func TestGetTreeForDisplay(t *testing.T) {
+	expected := "github.com/a/a@^1.0.0 (aaaaaaa)\n"
+	expected += "├── github.com/aa/aa@branch:main (bbbbbbb)\n"
+	expected += "│   └── github.com/aaa/aaa@1.2.3 (ccccccc)\n"
+	expected += "└── github.com/ab/ab@2.x.x (ddddddd)\n"
+	expected += "github.com/b/b@tag:beta (eeeeeee)\n"
+
+	assert.Equal(t, expected, GetTreeForDisplay(getTestTree(), -1))
+}
+
+func TestGetTreeForDisplayWithDepth(t *testing.T) {
+	expected := "github.com/a/a@^1.0.0 (aaaaaaa)\n"
+	expected += "├── github.com/aa/aa@branch:main (bbbbbbb)\n"
+	expected += "└── github.com/ab/ab@2.x.x (ddddddd)\n"
+	expected += "github.com/b/b@tag:beta (eeeeeee)\n"
+
+	assert.Equal(t, expected, GetTreeForDisplay(getTestTree(), 1))
+}
+
+func TestGetTreeForJson(t *testing.T) {
+	nodes := GetTreeForJson(getTestTree(), 0)
+
+	assert.Equal(t, []TreeJsonNode{
+		{Name: "github.com/a/a", Version: "^1.0.0", SHA: "aaaaaaaaaaaa", Dependencies: []TreeJsonNode{}},
+		{Name: "github.com/b/b", Version: "tag:beta", SHA: "eeeeeeeeeeee", Dependencies: []TreeJsonNode{}},
+	}, nodes)
+}
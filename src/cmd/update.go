@@ -2,6 +2,7 @@ package cmd
 
 import (
 	core "ahkpm/src/core"
+	"ahkpm/src/invariant"
 	_ "embed"
 	"fmt"
 
@@ -16,14 +17,37 @@ var UpdateCmd = &cobra.Command{
 	SuggestFor: []string{"upgrade"},
 	Short:      "Update package(s) to the latest version allowed by ahkpm.json",
 	Long:       updateLong,
-	Example:    "ahkpm update github.com/joshuacc/fake-package\nahkpm update gh:joshuacc/fake-package",
+	Example:    "ahkpm update github.com/joshuacc/fake-package\nahkpm update gh:joshuacc/fake-package\nahkpm update --latest\nahkpm update --interactive",
 	Aliases:    []string{"u"},
 	Run: func(cmd *cobra.Command, args []string) {
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		invariant.AssertNoError(err)
+
+		if cmd.Flag("interactive").Value.String() == "true" {
+			installer := core.Installer{Concurrency: concurrency}
+			err := updateInteractively(installer)
+			if err != nil {
+				fmt.Println(err.Error())
+			}
+			return
+		}
+		if cmd.Flag("latest").Value.String() == "true" {
+			packages := args
+			if len(packages) == 0 {
+				packages = GetDependencies(core.ManifestFromCwd().Dependencies)
+			}
+			installer := core.Installer{Concurrency: concurrency}
+			err := installer.Update(nil, packages)
+			if err != nil {
+				fmt.Println(err.Error())
+			}
+			return
+		}
 		if cmd.Flag("all").Value.String() == "true" {
 			deps := core.ManifestFromCwd().Dependencies
 			packages := GetDependencies(deps)
-			installer := core.Installer{}
-			err := installer.Update(packages...)
+			installer := core.Installer{Concurrency: concurrency}
+			err := installer.Update(packages, nil)
 			if err != nil {
 				fmt.Println(err.Error())
 			}
@@ -33,12 +57,13 @@ var UpdateCmd = &cobra.Command{
 			fmt.Println("Please specify a package name")
 			return
 		}
-		installer := core.Installer{}
-		err := installer.Update(args...)
+		installer := core.Installer{Concurrency: concurrency}
+		err = installer.Update(args, nil)
 		if err != nil {
 			fmt.Println(err.Error())
 		}
 	},
+	PostRun: collectCacheGarbageAutomatically,
 }
 
 func GetDependencies(set core.DependencySet) []string {
@@ -51,5 +76,8 @@ func GetDependencies(set core.DependencySet) []string {
 
 func init() {
 	UpdateCmd.Flags().BoolP("all", "a", false, "Updates all dependencies")
+	UpdateCmd.Flags().BoolP("interactive", "i", false, "Choose which outdated dependencies to update")
+	UpdateCmd.Flags().Bool("latest", false, "Updates ahkpm.json to the latest versions, even across major versions")
+	UpdateCmd.Flags().Int("concurrency", core.DefaultConcurrency, "Maximum number of packages to fetch at the same time")
 	RootCmd.AddCommand(UpdateCmd)
 }
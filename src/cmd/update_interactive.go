@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/c-bata/go-prompt"
+)
+
+// updateInteractively shows the outdated dependencies in ahkpm.json in a
+// table, lets the user pick how to update each of them, and then updates all
+// of the picked dependencies together
+func updateInteractively(installer core.Installer) error {
+	lm, err := core.LockManifestFromCwd()
+	if err != nil {
+		return err
+	}
+
+	outdated := core.GetUpdatableDependencies(core.NewPackagesRepository(), *lm, core.ManifestFromCwd().Dependencies)
+	if len(outdated) == 0 {
+		fmt.Println("All dependencies are up to date")
+		return nil
+	}
+
+	selected, ok := selectUpdates(outdated)
+	if !ok {
+		fmt.Println("Update cancelled.")
+		return nil
+	}
+
+	wantedNames := make([]string, 0)
+	latestNames := make([]string, 0)
+	for i, od := range outdated {
+		switch selected[i] {
+		case "wanted":
+			wantedNames = append(wantedNames, od.Name)
+		case "latest":
+			latestNames = append(latestNames, od.Name)
+		}
+	}
+
+	if len(wantedNames) == 0 && len(latestNames) == 0 {
+		fmt.Println("No dependencies selected.")
+		return nil
+	}
+
+	return installer.Update(wantedNames, latestNames)
+}
+
+// selectUpdates repeatedly shows the outdated dependencies along with how
+// each will be updated, and lets the user toggle them by number until they
+// confirm. It returns the choice for each dependency, or false if the user
+// cancelled.
+func selectUpdates(outdated []core.OutdatedDependency) ([]string, bool) {
+	selected := make([]string, len(outdated))
+	for i := range selected {
+		selected[i] = "none"
+	}
+
+	suggestions := make([]prompt.Suggest, len(outdated))
+	for i, od := range outdated {
+		suggestions[i] = prompt.Suggest{Text: strconv.Itoa(i + 1), Description: od.Name}
+	}
+	completer := func(d prompt.Document) []prompt.Suggest {
+		return prompt.FilterHasPrefix(suggestions, d.GetWordBeforeCursor(), false)
+	}
+
+	for {
+		fmt.Print("\n" + GetUpdateSelectionForDisplay(outdated, selected))
+		fmt.Println("\nEnter the numbers of dependencies to switch between " +
+			"none, wanted and latest. Press Enter with no numbers to update the selected dependencies, or enter q to cancel.")
+
+		input := strings.TrimSpace(prompt.Input("> ", completer))
+		if input == "" {
+			return selected, true
+		}
+		if input == "q" {
+			return nil, false
+		}
+
+		indexes, err := ParseUpdateSelection(input, len(outdated))
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		for _, i := range indexes {
+			selected[i] = NextUpdateChoice(outdated[i], selected[i])
+		}
+	}
+}
+
+// GetUpdateSelectionForDisplay lays out the outdated dependencies in a
+// numbered table, along with how each of them will be updated
+func GetUpdateSelectionForDisplay(outdated []core.OutdatedDependency, selected []string) string {
+	headers := []string{"#", "Name", "Requested", "Current", "Wanted", "Latest", "Update"}
+	rows := make([][]string, len(outdated))
+	for i, od := range outdated {
+		rows[i] = []string{
+			strconv.Itoa(i + 1),
+			od.Name,
+			od.Requested,
+			od.Current,
+			od.Wanted,
+			od.Latest,
+			selected[i],
+		}
+	}
+	return formatTable(headers, rows)
+}
+
+// ParseUpdateSelection converts space separated row numbers into indexes
+func ParseUpdateSelection(input string, rowCount int) ([]int, error) {
+	indexes := make([]int, 0)
+	for _, field := range strings.Fields(input) {
+		number, err := strconv.Atoi(field)
+		if err != nil || number < 1 || number > rowCount {
+			return nil, fmt.Errorf("%s is not a number between 1 and %d", field, rowCount)
+		}
+		indexes = append(indexes, number-1)
+	}
+	return indexes, nil
+}
+
+// NextUpdateChoice returns the choice after current among the ways in which
+// the dependency can be updated, wrapping around to "none"
+func NextUpdateChoice(od core.OutdatedDependency, current string) string {
+	choices := core.GetUpdateChoices(od)
+	for i, choice := range choices {
+		if choice == current {
+			return choices[(i+1)%len(choices)]
+		}
+	}
+	return choices[0]
+}
@@ -0,0 +1,46 @@
+package cmd_test
+
+import (
+	. "ahkpm/src/cmd"
+	. "ahkpm/src/core"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUpdateSelectionForDisplay(t *testing.T) {
+	outdated := []OutdatedDependency{
+		{Name: "github.com/a/a", Requested: "^1.0.0", Current: "1.0.0", Wanted: "1.1.0", Latest: "2.0.0"},
+		{Name: "github.com/b/b", Requested: "~2.0.0", Current: "2.0.0", Wanted: "2.0.1", Latest: "2.0.1"},
+	}
+
+	expected := "#\tName          \tRequested\tCurrent\tWanted\tLatest\tUpdate\n"
+	expected += "-\t--------------\t---------\t-------\t------\t------\t------\n"
+	expected += "1\tgithub.com/a/a\t^1.0.0   \t1.0.0  \t1.1.0 \t2.0.0 \tlatest\n"
+	expected += "2\tgithub.com/b/b\t~2.0.0   \t2.0.0  \t2.0.1 \t2.0.1 \tnone  \n"
+
+	assert.Equal(t, expected, GetUpdateSelectionForDisplay(outdated, []string{"latest", "none"}))
+}
+
+func TestParseUpdateSelection(t *testing.T) {
+	indexes, err := ParseUpdateSelection(" 2 1 ", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 0}, indexes)
+
+	_, err = ParseUpdateSelection("3", 2)
+	assert.EqualError(t, err, "3 is not a number between 1 and 2")
+
+	_, err = ParseUpdateSelection("a", 2)
+	assert.Error(t, err)
+}
+
+func TestNextUpdateChoice(t *testing.T) {
+	od := OutdatedDependency{Name: "github.com/a/a", Requested: "^1.0.0", Current: "1.0.0", Wanted: "1.1.0", Latest: "2.0.0"}
+	assert.Equal(t, "wanted", NextUpdateChoice(od, "none"))
+	assert.Equal(t, "latest", NextUpdateChoice(od, "wanted"))
+	assert.Equal(t, "none", NextUpdateChoice(od, "latest"))
+
+	withinRange := OutdatedDependency{Name: "github.com/b/b", Requested: "~2.0.0", Current: "2.0.0", Wanted: "2.0.1", Latest: "2.0.1"}
+	assert.Equal(t, "wanted", NextUpdateChoice(withinRange, "none"))
+	assert.Equal(t, "none", NextUpdateChoice(withinRange, "wanted"))
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"ahkpm/src/invariant"
+	"ahkpm/src/utils"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed verify-long.md
+var verifyLong string
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Checks that the packages in ahkpm-modules match ahkpm.lock",
+	Long:  verifyLong,
+	Run: func(cmd *cobra.Command, args []string) {
+		lm := getLockManifestOrExit()
+
+		issues, err := core.VerifyInstallation(core.NewPackagesRepository(), *lm)
+		if err != nil {
+			utils.Exit(err.Error())
+		}
+
+		if cmd.Flag("json").Value.String() == "true" {
+			jsonBytes, err := json.MarshalIndent(issues, "", "  ")
+			invariant.AssertNoError(err)
+			fmt.Println(string(jsonBytes))
+		} else if len(issues) == 0 {
+			fmt.Println("All packages match ahkpm.lock")
+		} else {
+			for _, issue := range issues {
+				fmt.Println(issue.String())
+			}
+			fmt.Println("\nRun `ahkpm install` to restore the packages in ahkpm.lock.")
+		}
+
+		if len(issues) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	verifyCmd.Flags().Bool("json", false, "Output the issues found as JSON")
+	RootCmd.AddCommand(verifyCmd)
+}
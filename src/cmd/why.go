@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	core "ahkpm/src/core"
+	"ahkpm/src/utils"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed why-long.md
+var whyLong string
+
+var whyCmd = &cobra.Command{
+	Use:     "why <package>",
+	Short:   "Explains why a package is installed",
+	Long:    whyLong,
+	Example: "ahkpm why gh:joshuacc/fake-package",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			utils.Exit("Please specify a package name")
+		}
+
+		lm := getLockManifestOrExit()
+
+		pkgName := core.CanonicalizeDependencyName(args[0])
+		paths := core.ResolvedDependencyTreeFromArray(lm.Resolved).PathsTo(pkgName)
+		fmt.Print(GetWhyForDisplay(pkgName, paths))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(whyCmd)
+}
+
+// GetWhyForDisplay describes each path through which a package was installed,
+// including the version that was requested at each step
+func GetWhyForDisplay(pkgName string, paths [][]core.ResolvedDependency) string {
+	if len(paths) == 0 {
+		return pkgName + " is not installed\n"
+	}
+
+	var output strings.Builder
+	output.WriteString(pkgName + " is installed because:\n")
+	for _, path := range paths {
+		output.WriteString("\n")
+		requester := "ahkpm.json"
+		for i, dep := range path {
+			indent := strings.Repeat("  ", i+1)
+			output.WriteString(fmt.Sprintf("%s%s requires %s@%s\n", indent, requester, dep.Name, dep.Version))
+			requester = dep.Name
+		}
+	}
+	return output.String()
+}
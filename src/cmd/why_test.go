@@ -0,0 +1,38 @@
+package cmd_test
+
+import (
+	. "ahkpm/src/cmd"
+	. "ahkpm/src/core"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWhyForDisplay(t *testing.T) {
+	paths := [][]ResolvedDependency{
+		{
+			{Name: "github.com/a/a", Version: "^1.0.0"},
+			{Name: "github.com/c/c", Version: "^1.2.0"},
+		},
+		{
+			{Name: "github.com/b/b", Version: "branch:main"},
+			{Name: "github.com/ba/ba", Version: "2.0.0"},
+			{Name: "github.com/c/c", Version: "^1.3.0"},
+		},
+	}
+
+	expected := "github.com/c/c is installed because:\n"
+	expected += "\n"
+	expected += "  ahkpm.json requires github.com/a/a@^1.0.0\n"
+	expected += "    github.com/a/a requires github.com/c/c@^1.2.0\n"
+	expected += "\n"
+	expected += "  ahkpm.json requires github.com/b/b@branch:main\n"
+	expected += "    github.com/b/b requires github.com/ba/ba@2.0.0\n"
+	expected += "      github.com/ba/ba requires github.com/c/c@^1.3.0\n"
+
+	assert.Equal(t, expected, GetWhyForDisplay("github.com/c/c", paths))
+}
+
+func TestGetWhyForDisplayWhenNotInstalled(t *testing.T) {
+	assert.Equal(t, "github.com/c/c is not installed\n", GetWhyForDisplay("github.com/c/c", [][]ResolvedDependency{}))
+}
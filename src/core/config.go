@@ -0,0 +1,85 @@
+package core
+
+import (
+	"ahkpm/src/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Config contains the user's settings from config.json in the ahkpm
+// directory. Settings missing from the file keep their default values.
+type Config struct {
+	// Offline prevents ahkpm from accessing the network, so that packages are
+	// only resolved from the cache
+	Offline bool `json:"offline"`
+	// MaxCacheSizeMB is the size the package cache may grow to before the
+	// least recently used packages are evicted. Zero means no limit.
+	MaxCacheSizeMB int `json:"maxCacheSizeMB"`
+	// MaxAgeDays is how many days a cached package may go unused before it
+	// is evicted. Zero means no limit.
+	MaxAgeDays int `json:"maxAgeDays"`
+}
+
+func NewConfig() *Config {
+	return &Config{}
+}
+
+// CacheLimits returns the limits on the package cache set in the config
+func (c *Config) CacheLimits() CacheLimits {
+	return CacheLimits{
+		MaxSize: int64(c.MaxCacheSizeMB) * 1024 * 1024,
+		MaxAge:  time.Duration(c.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// GetConfigPath returns the path of the user's config file
+func GetConfigPath() string {
+	return utils.GetAhkpmDir() + `\config.json`
+}
+
+// userConfig holds the config once it has been read by GetConfig
+var userConfig struct {
+	once   sync.Once
+	config *Config
+	err    error
+}
+
+// GetConfig reads the user's config file the first time it is called, and
+// returns the same settings afterwards. It is only called by commands which
+// need the settings, so that others work even if the file is invalid.
+func GetConfig() (*Config, error) {
+	userConfig.once.Do(func() {
+		ahkpmDir, err := utils.LookupAhkpmDir()
+		if err != nil {
+			userConfig.err = err
+			return
+		}
+		userConfig.config, userConfig.err = ConfigFromFile(ahkpmDir + `\config.json`)
+	})
+	return userConfig.config, userConfig.err
+}
+
+// ConfigFromFile reads the config file at path. If there is no file, the
+// default config is returned.
+func ConfigFromFile(path string) (*Config, error) {
+	config := NewConfig()
+
+	jsonBytes, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(jsonBytes, config)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %w", path, err)
+	}
+	return config, nil
+}
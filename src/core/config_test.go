@@ -0,0 +1,40 @@
+package core_test
+
+import (
+	. "ahkpm/src/core"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"offline": true}`), 0644))
+
+	config, err := ConfigFromFile(path)
+	assert.NoError(t, err)
+	assert.True(t, config.Offline)
+}
+
+func TestConfigCacheLimits(t *testing.T) {
+	config := Config{MaxCacheSizeMB: 2, MaxAgeDays: 3}
+	assert.Equal(t, CacheLimits{MaxSize: 2 * 1024 * 1024, MaxAge: 72 * time.Hour}, config.CacheLimits())
+	assert.True(t, NewConfig().CacheLimits().IsEmpty())
+}
+
+func TestConfigFromMissingFile(t *testing.T) {
+	config, err := ConfigFromFile(filepath.Join(t.TempDir(), "config.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, NewConfig(), config)
+}
+
+func TestConfigFromInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"offline": `), 0644))
+
+	_, err := ConfigFromFile(path)
+	assert.ErrorContains(t, err, "Error parsing")
+}
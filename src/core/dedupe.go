@@ -0,0 +1,144 @@
+package core
+
+import (
+	"golang.org/x/exp/slices"
+)
+
+// DedupedPackage is a package whose copies were combined by Dedupe
+type DedupedPackage struct {
+	Name string
+	// Version is the tag or version which every copy is at
+	Version string
+	// FromPaths lists where the copies were installed before
+	FromPaths []string
+	// ToPaths lists where the package is installed now
+	ToPaths []string
+}
+
+// Dedupe combines packages in the lockfile which are installed at more than
+// one path into a single shared copy, then reinstalls. It returns the
+// packages which were deduped.
+func (i Installer) Dedupe(lm LockManifest) ([]DedupedPackage, error) {
+	manifest := ManifestFromCwd()
+
+	deduped, names := DedupeResolvedDependencies(ResolvedDependencyTreeFromArray(lm.Resolved))
+	if len(names) == 0 {
+		return []DedupedPackage{}, nil
+	}
+
+	laidOut, err := deduped.WithInstallLayout(manifest.InstallLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	dedupedPackages := make([]DedupedPackage, 0, len(names))
+	for _, name := range names {
+		dedupedPackage := DedupedPackage{
+			Name:      name,
+			FromPaths: getUniqueInstallPaths(lm.Resolved, name),
+			ToPaths:   getUniqueInstallPaths(laidOut.Flatten(), name),
+		}
+		for _, dep := range laidOut.Flatten() {
+			if dep.Name == name {
+				dedupedPackage.Version = dep.Version
+				if dep.Tag != "" {
+					dedupedPackage.Version = dep.Tag
+				}
+				break
+			}
+		}
+		dedupedPackages = append(dedupedPackages, dedupedPackage)
+	}
+
+	err = i.apply(lm.Resolved, deduped, nil, lm.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	return dedupedPackages, nil
+}
+
+// DedupeResolvedDependencies finds packages which are installed at more than
+// one path, and marks them as deduped so that they share a single copy. The
+// resolver selects only one version of each package, so every copy is at the
+// same commit and deduping only moves them. Packages whose copies are somehow
+// at different commits are left alone. It returns the new tree along with the
+// names of the deduped packages.
+func DedupeResolvedDependencies(tree ResolvedDependencyTree) (ResolvedDependencyTree, []string) {
+	nodesByName := make(map[string][]TreeNode[ResolvedDependency])
+	names := make([]string, 0)
+	_ = tree.ForEach(func(n TreeNode[ResolvedDependency]) error {
+		if _, ok := nodesByName[n.Value.Name]; !ok {
+			names = append(names, n.Value.Name)
+		}
+		nodesByName[n.Value.Name] = append(nodesByName[n.Value.Name], n)
+		return nil
+	})
+
+	dedupedNames := make([]string, 0)
+	for _, name := range names {
+		nodes := nodesByName[name]
+		paths := make([]string, 0)
+		isSameCommit := true
+		for _, node := range nodes {
+			if !slices.Contains(paths, node.Value.InstallPath) {
+				paths = append(paths, node.Value.InstallPath)
+			}
+			isSameCommit = isSameCommit && node.Value.SHA == nodes[0].Value.SHA
+		}
+		if len(paths) < 2 || !isSameCommit {
+			continue
+		}
+		dedupedNames = append(dedupedNames, name)
+	}
+
+	return markDeduped(tree, func(dep ResolvedDependency) bool {
+		return slices.Contains(dedupedNames, dep.Name)
+	}), dedupedNames
+}
+
+// markDeduped returns a copy of the tree in which every dependency for which
+// isDeduped returns true is marked as deduped
+func markDeduped(nodes ResolvedDependencyTree, isDeduped func(dep ResolvedDependency) bool) ResolvedDependencyTree {
+	result := make(ResolvedDependencyTree, len(nodes))
+	for i, node := range nodes {
+		if isDeduped(node.Value) {
+			node.Value.Deduped = true
+		}
+		node.Children = markDeduped(node.Children, isDeduped)
+		result[i] = node
+	}
+	return result
+}
+
+// KeepDeduped marks the dependencies which were deduped in the previous
+// lockfile as deduped again, as long as they are still at the same commit.
+// Re-resolved packages are built without the marker, so without this their
+// copies would move back under the packages which require them.
+func (r ResolvedDependencyTree) KeepDeduped(previous []ResolvedDependency) ResolvedDependencyTree {
+	deduped := make(map[string]bool)
+	for _, dep := range previous {
+		if dep.Deduped {
+			deduped[dep.Name+" "+dep.SHA] = true
+		}
+	}
+	if len(deduped) == 0 {
+		return r
+	}
+
+	return markDeduped(r, func(dep ResolvedDependency) bool {
+		return deduped[dep.Name+" "+dep.SHA]
+	})
+}
+
+// getUniqueInstallPaths returns each path at which the named package is
+// installed
+func getUniqueInstallPaths(resolved []ResolvedDependency, name string) []string {
+	paths := make([]string, 0)
+	for _, dep := range resolved {
+		if dep.Name == name && !slices.Contains(paths, dep.InstallPath) {
+			paths = append(paths, dep.InstallPath)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,67 @@
+package core_test
+
+import (
+	. "ahkpm/src/core"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeResolvedDependencies(t *testing.T) {
+	// x and y both need c, which was resolved to c@1.2.0 for both of them
+	tree := ResolvedDependencyTreeFromArray([]ResolvedDependency{
+		{Name: "github.com/a/a", Version: "1.0.0", Tag: "1.0.0", SHA: "a1", InstallPath: "ahkpm-modules/github.com/a/a"},
+		{Name: "github.com/x/x", Version: "^1.0.0", Tag: "1.0.0", SHA: "x1", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/x/x"},
+		{Name: "github.com/c/c", Version: "^1.0.0", Tag: "1.2.0", SHA: "c12", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/x/x/ahkpm-modules/github.com/c/c"},
+		{Name: "github.com/y/y", Version: "^1.0.0", Tag: "1.0.0", SHA: "y1", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/y/y"},
+		{Name: "github.com/c/c", Version: "^1.1.0", Tag: "1.2.0", SHA: "c12", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/y/y/ahkpm-modules/github.com/c/c"},
+		{Name: "github.com/b/b", Version: "1.0.0", Tag: "1.0.0", SHA: "b1", InstallPath: "ahkpm-modules/github.com/b/b"},
+		{Name: "github.com/d/d", Version: "1.0.0", Tag: "1.0.0", SHA: "d1", InstallPath: "ahkpm-modules/github.com/b/b/ahkpm-modules/github.com/d/d"},
+	})
+
+	deduped, names := DedupeResolvedDependencies(tree)
+	assert.Equal(t, []string{"github.com/c/c"}, names)
+
+	laidOut, err := deduped.WithInstallLayout(NestedInstallLayout)
+	assert.Nil(t, err)
+
+	for _, dep := range laidOut.Flatten() {
+		if dep.Name != "github.com/c/c" {
+			assert.False(t, dep.Deduped)
+			continue
+		}
+		// Both copies move to the closest package which both x and y have
+		// in common
+		assert.True(t, dep.Deduped)
+		assert.Equal(t, "c12", dep.SHA)
+		assert.Equal(t, "1.2.0", dep.Tag)
+		assert.Equal(t, "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c", dep.InstallPath)
+		assert.Len(t, dep.RequiredBy, 2)
+	}
+
+	// Each copy keeps the version it requires
+	paths := laidOut.PathsTo("github.com/c/c")
+	assert.Equal(t, "^1.0.0", paths[0][2].Version)
+	assert.Equal(t, "^1.1.0", paths[1][2].Version)
+
+	// The placement survives being saved to and read from the lockfile
+	reread, err := ResolvedDependencyTreeFromArray(laidOut.Flatten()).WithInstallLayout(NestedInstallLayout)
+	assert.Nil(t, err)
+	assert.Equal(t, laidOut.Flatten(), reread.Flatten())
+
+	_, names = DedupeResolvedDependencies(reread)
+	assert.Equal(t, []string{}, names)
+}
+
+func TestDedupeResolvedDependenciesWithDifferentCommits(t *testing.T) {
+	tree := ResolvedDependencyTreeFromArray([]ResolvedDependency{
+		{Name: "github.com/a/a", Version: "1.0.0", Tag: "1.0.0", SHA: "a1", InstallPath: "ahkpm-modules/github.com/a/a"},
+		{Name: "github.com/c/c", Version: "^1.0.0", Tag: "1.0.0", SHA: "c1", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c"},
+		{Name: "github.com/b/b", Version: "1.0.0", Tag: "1.0.0", SHA: "b1", InstallPath: "ahkpm-modules/github.com/b/b"},
+		{Name: "github.com/c/c", Version: "^2.0.0", Tag: "2.0.0", SHA: "c2", InstallPath: "ahkpm-modules/github.com/b/b/ahkpm-modules/github.com/c/c"},
+	})
+
+	deduped, names := DedupeResolvedDependencies(tree)
+	assert.Equal(t, []string{}, names)
+	assert.Equal(t, tree.Flatten(), deduped.Flatten())
+}
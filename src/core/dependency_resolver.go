@@ -2,23 +2,32 @@ package core
 
 type DependencyResolver interface {
 	// Resolve takes in a list of packages and versions, scans them recursively
-	// and returns a tree of all transitive dependencies. If a package occurs
-	// more than once, the specified versions are compared. In the case of a conflict,
-	// an error is returned. For the time being, any difference in versions is
-	// considered a conflict.
+	// and returns a tree of all transitive dependencies. Each package is
+	// resolved to a single version which satisfies every range requested for
+	// it, backtracking to older versions of dependent packages when needed. If
+	// no such set of versions exists, the returned error explains why.
 	Resolve(deps DependencySet) (resolvedDependencies ResolvedDependencyTree, err error)
 
+	// WithConcurrency sets the maximum number of packages fetched at once
+	WithConcurrency(concurrency int) DependencyResolver
+
 	// WithPackagesRepository is used for testing
 	WithPackagesRepository(pr PackagesRepository) DependencyResolver
 }
 
+// DefaultConcurrency is the number of packages fetched at once during
+// resolution unless otherwise specified
+const DefaultConcurrency = 8
+
 type resolver struct {
 	packagesRepository PackagesRepository
+	concurrency        int
 }
 
 func NewDependencyResolver() DependencyResolver {
 	return &resolver{
 		packagesRepository: NewPackagesRepository(),
+		concurrency:        DefaultConcurrency,
 	}
 }
 
@@ -27,12 +36,12 @@ func (r *resolver) Resolve(deps DependencySet) (ResolvedDependencyTree, error) {
 		return ResolvedDependencyTree{}, nil
 	}
 
-	resolvedDepNodes, err := r.innerResolve(deps)
+	selected, err := newDependencySolver(r.packagesRepository, r.concurrency).Solve(deps)
 	if err != nil {
 		return ResolvedDependencyTree{}, err
 	}
 
-	depNodesWithInstallPath := resolvedDepNodes.EnsureInstallPaths()
+	depNodesWithInstallPath := buildResolvedDependencyTree(deps, selected).EnsureInstallPaths()
 
 	err = depNodesWithInstallPath.CheckForConflicts()
 	if err != nil {
@@ -42,31 +51,9 @@ func (r *resolver) Resolve(deps DependencySet) (ResolvedDependencyTree, error) {
 	return depNodesWithInstallPath, nil
 }
 
-func (r *resolver) innerResolve(depSet DependencySet) (ResolvedDependencyTree, error) {
-	if depSet.Len() == 0 {
-		return ResolvedDependencyTree{}, nil
-	}
-
-	resolvedDepNodes := make(ResolvedDependencyTree, depSet.Len())
-
-	// For each dependency, get its transitive dependencies.
-	for i, dep := range depSet.AsArray() {
-		partiallyResolvedDepNode, err := getResolvedDependency(r.packagesRepository, NewTreeNode(dep))
-		if err != nil {
-			return nil, err
-		}
-
-		children, err := r.innerResolve(partiallyResolvedDepNode.Value.Dependencies)
-		if err != nil {
-			return nil, err
-		}
-
-		fullyResolvedDepNode := partiallyResolvedDepNode.WithChildren(children)
-
-		resolvedDepNodes[i] = fullyResolvedDepNode
-	}
-
-	return resolvedDepNodes, nil
+func (r *resolver) WithConcurrency(concurrency int) DependencyResolver {
+	r.concurrency = concurrency
+	return r
 }
 
 func (r *resolver) WithPackagesRepository(pr PackagesRepository) DependencyResolver {
@@ -74,24 +61,28 @@ func (r *resolver) WithPackagesRepository(pr PackagesRepository) DependencyResol
 	return r
 }
 
-func getResolvedDependency(pr PackagesRepository, depNode TreeNode[Dependency]) (*TreeNode[ResolvedDependency], error) {
-	sha, err := pr.GetResolvedDependencySHA(depNode.Value)
-	if err != nil {
-		return nil, err
-	}
+// buildResolvedDependencyTree builds a tree for the given dependencies, using
+// the versions which were selected for each package by the solver. The solver
+// has already rejected any cycles, so the recursion always terminates.
+func buildResolvedDependencyTree(depSet DependencySet, selected map[string]selectedVersion) ResolvedDependencyTree {
+	resolvedDepNodes := make(ResolvedDependencyTree, depSet.Len())
 
-	resolved := ResolvedDependency{
-		Name:    depNode.Value.Name(),
-		Version: depNode.Value.Version().String(),
-		SHA:     sha,
-	}
+	for i, dep := range depSet.AsArray() {
+		selection := selected[dep.Name()]
+
+		resolved := ResolvedDependency{
+			Name:    dep.Name(),
+			Version: dep.Version().String(),
+			Tag:     selection.tag(),
+			SHA:     selection.sha,
+			Source:  getGitUrl(dep.Name()),
+		}
 
-	childDependencies, err := pr.GetPackageDependencies(resolved)
-	if err != nil {
-		return nil, err
-	}
+		children := buildResolvedDependencyTree(selection.dependencies, selected)
 
-	resolvedNode := NewTreeNode(resolved.WithDependencies(*childDependencies))
+		resolvedDepNodes[i] = NewTreeNode(resolved.WithDependencies(selection.dependencies)).
+			WithChildren(children)
+	}
 
-	return &resolvedNode, nil
+	return resolvedDepNodes
 }
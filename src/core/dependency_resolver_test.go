@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestResolveWithNoDependencies(t *testing.T) {
@@ -19,7 +20,7 @@ func TestResolveWithNoDependencies(t *testing.T) {
 
 func TestResolveWithNoChildDependencies(t *testing.T) {
 	mockPR := &mocks.MockPackagesRepository{}
-	dr := NewDependencyResolver().WithPackagesRepository(mockPR)
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
 	dep1 := NewDependency("github.com/ahkpm/ahkpm", NewVersion(SemVerExact, "1.2.3"))
 	deps := NewDependencySet().AddDependency(dep1)
 	partiallyResolvedDep := ResolvedDependency{
@@ -29,6 +30,8 @@ func TestResolveWithNoChildDependencies(t *testing.T) {
 	}
 	fullyResolvedDep := partiallyResolvedDep.WithDependencies(NewDependencySet())
 	fullyResolvedDep.InstallPath = "ahkpm-modules/github.com/ahkpm/ahkpm"
+	fullyResolvedDep.Tag = "1.2.3"
+	fullyResolvedDep.Source = "https://github.com/ahkpm/ahkpm.git"
 	mockPR.On("GetResolvedDependencySHA", dep1).Return(partiallyResolvedDep.SHA, nil)
 	newSet := NewDependencySet()
 	mockPR.On("GetPackageDependencies", partiallyResolvedDep).Return(&newSet, nil)
@@ -45,7 +48,7 @@ func TestResolveWithNoChildDependencies(t *testing.T) {
 
 func TestResolveWithChildDependencies(t *testing.T) {
 	mockPR := &mocks.MockPackagesRepository{}
-	dr := NewDependencyResolver().WithPackagesRepository(mockPR)
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
 	dep1 := NewDependency("github.com/ahkpm/ahkpm", NewVersion(SemVerExact, "1.2.3"))
 	deps := NewDependencySet().AddDependency(dep1)
 	partiallyResolvedDep := ResolvedDependency{
@@ -55,6 +58,8 @@ func TestResolveWithChildDependencies(t *testing.T) {
 	}
 	fullyResolvedDep := partiallyResolvedDep.WithDependencies(NewDependencySet())
 	fullyResolvedDep.InstallPath = "ahkpm-modules/github.com/ahkpm/ahkpm"
+	fullyResolvedDep.Tag = "1.2.3"
+	fullyResolvedDep.Source = "https://github.com/ahkpm/ahkpm.git"
 
 	childDep1 := NewDependency("github.com/abcd/abcd", NewVersion(SemVerExact, "1.2.3"))
 	childDeps := NewDependencySet().AddDependency(childDep1)
@@ -66,6 +71,8 @@ func TestResolveWithChildDependencies(t *testing.T) {
 	}
 	fullyResolvedChildDep := partiallyResolvedChildDep.WithDependencies(NewDependencySet())
 	fullyResolvedChildDep.InstallPath = "ahkpm-modules/github.com/ahkpm/ahkpm/ahkpm-modules/github.com/abcd/abcd"
+	fullyResolvedChildDep.Tag = "1.2.3"
+	fullyResolvedChildDep.Source = "https://github.com/abcd/abcd.git"
 
 	mockPR.On("GetResolvedDependencySHA", dep1).Return(partiallyResolvedDep.SHA, nil)
 	emptySet := NewDependencySet()
@@ -88,7 +95,7 @@ func TestResolveWithChildDependencies(t *testing.T) {
 
 func TestResolveWithConflictingChildDepencyVersions(t *testing.T) {
 	mockPR := &mocks.MockPackagesRepository{}
-	dr := NewDependencyResolver().WithPackagesRepository(mockPR)
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
 	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.2.3"))
 	depB := NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.2.3"))
 	deps := NewDependencySet().AddDependency(depA).AddDependency(depB)
@@ -145,7 +152,7 @@ func TestResolveWithConflictingChildDepencyVersions(t *testing.T) {
 
 func TestResolveWithErrorGettingDependencySHA(t *testing.T) {
 	mockPR := &mocks.MockPackagesRepository{}
-	dr := NewDependencyResolver().WithPackagesRepository(mockPR)
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
 
 	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.2.3"))
 	deps := NewDependencySet().AddDependency(depA)
@@ -159,7 +166,7 @@ func TestResolveWithErrorGettingDependencySHA(t *testing.T) {
 
 func TestResolveWithErrorGettingPackageDependencies(t *testing.T) {
 	mockPR := &mocks.MockPackagesRepository{}
-	dr := NewDependencyResolver().WithPackagesRepository(mockPR)
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
 
 	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.2.3"))
 	deps := NewDependencySet().AddDependency(depA)
@@ -177,3 +184,289 @@ func TestResolveWithErrorGettingPackageDependencies(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestResolveWithOverlappingChildDependencyRanges(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.0.0"))
+	depB := NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.0.0"))
+	deps := NewDependencySet().AddDependency(depA).AddDependency(depB)
+	sharedDepA := NewDependency("github.com/shared/dep", NewVersion(SemVerRange, "^1.2.0"))
+	aDeps := NewDependencySet().AddDependency(sharedDepA)
+	sharedDepB := NewDependency("github.com/shared/dep", NewVersion(SemVerRange, "^1.3.0"))
+	bDeps := NewDependencySet().AddDependency(sharedDepB)
+	pinnedSharedDep := NewDependency("github.com/shared/dep", NewVersion(SemVerExact, "1.4.0"))
+	emptySet := NewDependencySet()
+
+	mockPR.On("GetResolvedDependencySHA", depA).Return("aaaa", nil)
+	mockPR.On("GetResolvedDependencySHA", depB).Return("bbbb", nil)
+	mockPR.On("GetResolvedDependencySHA", pinnedSharedDep).Return("shared-1.4.0", nil)
+	mockPR.On("GetVersions", "github.com/shared/dep").Return([]string{"1.2.0", "1.2.5", "1.3.0", "1.4.0", "2.0.0"}, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depA.Name()
+	})).Return(&aDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depB.Name()
+	})).Return(&bDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == sharedDepA.Name()
+	})).Return(&emptySet, nil)
+
+	tree, err := dr.Resolve(deps)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "^1.2.0", tree[0].Children[0].Value.Version)
+	assert.Equal(t, "shared-1.4.0", tree[0].Children[0].Value.SHA)
+	assert.Equal(t, "^1.3.0", tree[1].Children[0].Value.Version)
+	assert.Equal(t, "shared-1.4.0", tree[1].Children[0].Value.SHA)
+}
+
+func TestResolveWithNonOverlappingChildDependencyRanges(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.0.0"))
+	depB := NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.0.0"))
+	deps := NewDependencySet().AddDependency(depA).AddDependency(depB)
+	sharedDepA := NewDependency("github.com/shared/dep", NewVersion(SemVerRange, "^1.2.0"))
+	aDeps := NewDependencySet().AddDependency(sharedDepA)
+	sharedDepB := NewDependency("github.com/shared/dep", NewVersion(SemVerRange, "^2.0.0"))
+	bDeps := NewDependencySet().AddDependency(sharedDepB)
+	emptySet := NewDependencySet()
+
+	mockPR.On("GetResolvedDependencySHA", depA).Return("aaaa", nil)
+	mockPR.On("GetResolvedDependencySHA", depB).Return("bbbb", nil)
+	mockPR.On("GetVersions", "github.com/shared/dep").Return([]string{"1.2.0", "1.2.5", "2.0.0"}, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depA.Name()
+	})).Return(&aDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depB.Name()
+	})).Return(&bDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == sharedDepA.Name()
+	})).Return(&emptySet, nil)
+
+	_, err := dr.Resolve(deps)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No version of github.com/shared/dep satisfies all requirements")
+	assert.Contains(t, err.Error(), "github.com/a/a@1.0.0 needs github.com/shared/dep@^1.2.0")
+	assert.Contains(t, err.Error(), "github.com/b/b@1.0.0 needs github.com/shared/dep@^2.0.0")
+}
+
+func TestResolveBacktracksToCompatibleParentVersion(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerRange, "^1.0.0"))
+	depB := NewDependency("github.com/b/b", NewVersion(SemVerExact, "3.1.0"))
+	deps := NewDependencySet().AddDependency(depA).AddDependency(depB)
+	a120Deps := NewDependencySet().AddDependency(NewDependency("github.com/c/c", NewVersion(SemVerRange, "^2.0.0")))
+	a110Deps := NewDependencySet().AddDependency(NewDependency("github.com/c/c", NewVersion(SemVerRange, "^1.0.0")))
+	bDeps := NewDependencySet().AddDependency(NewDependency("github.com/c/c", NewVersion(SemVerRange, "^1.0.0")))
+	emptySet := NewDependencySet()
+
+	mockPR.On("GetVersions", "github.com/a/a").Return([]string{"1.1.0", "1.2.0"}, nil)
+	mockPR.On("GetVersions", "github.com/c/c").Return([]string{"1.0.0", "2.0.0"}, nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.2.0"))).Return("a-1.2.0", nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.1.0"))).Return("a-1.1.0", nil)
+	mockPR.On("GetResolvedDependencySHA", depB).Return("b-3.1.0", nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/c/c", NewVersion(SemVerExact, "1.0.0"))).Return("c-1.0.0", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.SHA == "a-1.2.0"
+	})).Return(&a120Deps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.SHA == "a-1.1.0"
+	})).Return(&a110Deps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.SHA == "b-3.1.0"
+	})).Return(&bDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == "github.com/c/c"
+	})).Return(&emptySet, nil)
+
+	tree, err := dr.Resolve(deps)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "a-1.1.0", tree[0].Value.SHA)
+	assert.Equal(t, "c-1.0.0", tree[0].Children[0].Value.SHA)
+	assert.Equal(t, "b-3.1.0", tree[1].Value.SHA)
+	assert.Equal(t, "c-1.0.0", tree[1].Children[0].Value.SHA)
+}
+
+func TestResolveExplainsFailureAfterBacktracking(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerRange, "^1.0.0"))
+	depB := NewDependency("github.com/b/b", NewVersion(SemVerExact, "3.1.0"))
+	deps := NewDependencySet().AddDependency(depA).AddDependency(depB)
+	aDeps := NewDependencySet().AddDependency(NewDependency("github.com/c/c", NewVersion(SemVerRange, "^2.0.0")))
+	bDeps := NewDependencySet().AddDependency(NewDependency("github.com/c/c", NewVersion(SemVerRange, "^1.0.0")))
+
+	mockPR.On("GetVersions", "github.com/a/a").Return([]string{"1.1.0", "1.2.0"}, nil)
+	mockPR.On("GetVersions", "github.com/c/c").Return([]string{"1.0.0", "2.0.0"}, nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.2.0"))).Return("a-1.2.0", nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.1.0"))).Return("a-1.1.0", nil)
+	mockPR.On("GetResolvedDependencySHA", depB).Return("b-3.1.0", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == "github.com/a/a"
+	})).Return(&aDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == "github.com/b/b"
+	})).Return(&bDeps, nil)
+
+	_, err := dr.Resolve(deps)
+
+	expected := "Unable to resolve dependencies:\n" +
+		"  Tried github.com/a/a@1.2.0, but:\n" +
+		"    No version of github.com/c/c satisfies all requirements:\n" +
+		"      github.com/a/a@1.2.0 needs github.com/c/c@^2.0.0\n" +
+		"      github.com/b/b@3.1.0 needs github.com/c/c@^1.0.0\n" +
+		"  Tried github.com/a/a@1.1.0, but:\n" +
+		"    No version of github.com/c/c satisfies all requirements:\n" +
+		"      github.com/a/a@1.1.0 needs github.com/c/c@^2.0.0\n" +
+		"      github.com/b/b@3.1.0 needs github.com/c/c@^1.0.0"
+	assert.EqualError(t, err, expected)
+}
+
+func TestResolveWithDependencyCycle(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.0.0"))
+	depB := NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.0.0"))
+	deps := NewDependencySet().AddDependency(depA)
+	aDeps := NewDependencySet().AddDependency(depB)
+	bDeps := NewDependencySet().AddDependency(depA)
+
+	mockPR.On("GetResolvedDependencySHA", depA).Return("aaaa", nil)
+	mockPR.On("GetResolvedDependencySHA", depB).Return("bbbb", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depA.Name()
+	})).Return(&aDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depB.Name()
+	})).Return(&bDeps, nil)
+
+	_, err := dr.Resolve(deps)
+
+	assert.EqualError(t, err, "Dependency cycle detected: github.com/a/a -> github.com/b/b -> github.com/a/a")
+}
+
+func TestResolveWithDependencyCycleRequiringAnotherVersion(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.0.0"))
+	depB := NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.0.0"))
+	depA2 := NewDependency("github.com/a/a", NewVersion(SemVerRange, "^2.0.0"))
+	deps := NewDependencySet().AddDependency(depA)
+	aDeps := NewDependencySet().AddDependency(depB)
+	bDeps := NewDependencySet().AddDependency(depA2)
+
+	mockPR.On("GetResolvedDependencySHA", depA).Return("aaaa", nil)
+	mockPR.On("GetResolvedDependencySHA", depB).Return("bbbb", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depA.Name()
+	})).Return(&aDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depB.Name()
+	})).Return(&bDeps, nil)
+
+	_, err := dr.Resolve(deps)
+
+	assert.EqualError(t, err, "Dependency cycle detected: github.com/a/a -> github.com/b/b -> github.com/a/a")
+}
+
+func TestResolveBacktracksFromDependencyCycle(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.0.0"))
+	deps := NewDependencySet().AddDependency(depA)
+	aDeps := NewDependencySet().AddDependency(NewDependency("github.com/b/b", NewVersion(SemVerRange, "^1.0.0")))
+	// Only the newest version of b depends on a
+	b110Deps := NewDependencySet().AddDependency(NewDependency("github.com/a/a", NewVersion(SemVerRange, "^1.0.0")))
+	emptySet := NewDependencySet()
+
+	mockPR.On("GetResolvedDependencySHA", depA).Return("a-1.0.0", nil)
+	mockPR.On("GetVersions", "github.com/b/b").Return([]string{"1.0.0", "1.1.0"}, nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.1.0"))).Return("b-1.1.0", nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.0.0"))).Return("b-1.0.0", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depA.Name()
+	})).Return(&aDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.SHA == "b-1.1.0"
+	})).Return(&b110Deps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.SHA == "b-1.0.0"
+	})).Return(&emptySet, nil)
+
+	tree, err := dr.Resolve(deps)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "b-1.0.0", tree[0].Children[0].Value.SHA)
+}
+
+func TestResolveExplainsDependencyCycleAfterBacktracking(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.0.0"))
+	deps := NewDependencySet().AddDependency(depA)
+	aDeps := NewDependencySet().AddDependency(NewDependency("github.com/b/b", NewVersion(SemVerRange, "^1.0.0")))
+	bDeps := NewDependencySet().AddDependency(NewDependency("github.com/a/a", NewVersion(SemVerRange, "^1.0.0")))
+
+	mockPR.On("GetResolvedDependencySHA", depA).Return("a-1.0.0", nil)
+	mockPR.On("GetVersions", "github.com/b/b").Return([]string{"1.0.0", "1.1.0"}, nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.1.0"))).Return("b-1.1.0", nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.0.0"))).Return("b-1.0.0", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depA.Name()
+	})).Return(&aDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == "github.com/b/b"
+	})).Return(&bDeps, nil)
+
+	_, err := dr.Resolve(deps)
+
+	expected := "Unable to resolve dependencies:\n" +
+		"  Tried github.com/b/b@1.1.0, but:\n" +
+		"    Dependency cycle detected: github.com/a/a -> github.com/b/b -> github.com/a/a\n" +
+		"  Tried github.com/b/b@1.0.0, but:\n" +
+		"    Dependency cycle detected: github.com/a/a -> github.com/b/b -> github.com/a/a"
+	assert.EqualError(t, err, expected)
+}
+
+func TestResolvePrefetchesDependencies(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	dr := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(2)
+	depA := NewDependency("github.com/a/a", NewVersion(SemVerExact, "1.0.0"))
+	depB := NewDependency("github.com/b/b", NewVersion(SemVerExact, "1.0.0"))
+	deps := NewDependencySet().AddDependency(depA).AddDependency(depB)
+	sharedRange := NewDependency("github.com/shared/dep", NewVersion(SemVerRange, "^1.0.0"))
+	aDeps := NewDependencySet().AddDependency(sharedRange)
+	sharedExact := NewDependency("github.com/shared/dep", NewVersion(SemVerExact, "1.0.0"))
+	bDeps := NewDependencySet().AddDependency(sharedExact)
+	sharedLatest := NewDependency("github.com/shared/dep", NewVersion(SemVerExact, "1.1.0"))
+	emptySet := NewDependencySet()
+
+	mockPR.On("GetResolvedDependencySHA", depA).Return("aaaa", nil)
+	mockPR.On("GetResolvedDependencySHA", depB).Return("bbbb", nil)
+	mockPR.On("GetVersions", "github.com/shared/dep").Return([]string{"1.0.0", "1.1.0"}, nil)
+	mockPR.On("GetResolvedDependencySHA", sharedExact).Return("shared-1.0.0", nil)
+	mockPR.On("GetResolvedDependencySHA", sharedLatest).Return("shared-1.1.0", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depA.Name()
+	})).Return(&aDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == depB.Name()
+	})).Return(&bDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == sharedRange.Name()
+	})).Return(&emptySet, nil)
+
+	tree, err := dr.Resolve(deps)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "shared-1.0.0", tree[0].Children[0].Value.SHA)
+	// The latest version in a's range is looked up as soon as a is selected,
+	// before the solver learns that b requires an older version
+	mockPR.AssertCalled(t, "GetResolvedDependencySHA", sharedLatest)
+}
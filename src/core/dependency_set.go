@@ -130,3 +130,60 @@ func (ds DependencySet) Contains(depName string) bool {
 	_, ok := ds._set[depName]
 	return ok
 }
+
+// DependencySetDiff describes the changes between two dependency sets
+type DependencySetDiff struct {
+	Added   []Dependency
+	Changed []VersionChange
+	Removed []Dependency
+}
+
+// Diff returns the changes needed to turn this set into the other set
+func (ds DependencySet) Diff(other DependencySet) DependencySetDiff {
+	diff := DependencySetDiff{
+		Added:   make([]Dependency, 0),
+		Changed: make([]VersionChange, 0),
+		Removed: make([]Dependency, 0),
+	}
+
+	for _, otherDep := range other.AsArray() {
+		dep, ok := ds._set[otherDep.Name()]
+		if !ok {
+			diff.Added = append(diff.Added, otherDep)
+		} else if !dep.Equals(otherDep) {
+			diff.Changed = append(diff.Changed, VersionChange{
+				Name: otherDep.Name(),
+				From: dep.Version(),
+				To:   otherDep.Version(),
+			})
+		}
+	}
+
+	for _, dep := range ds.AsArray() {
+		if _, ok := other._set[dep.Name()]; !ok {
+			diff.Removed = append(diff.Removed, dep)
+		}
+	}
+
+	return diff
+}
+
+// IsEmpty returns true if there are no changes
+func (diff DependencySetDiff) IsEmpty() bool {
+	return len(diff.Added) == 0 && len(diff.Changed) == 0 && len(diff.Removed) == 0
+}
+
+// String lists the changes, one per line
+func (diff DependencySetDiff) String() string {
+	output := ""
+	for _, dep := range diff.Added {
+		output += "  + " + dep.Name() + "@" + dep.Version().String() + "\n"
+	}
+	for _, change := range diff.Changed {
+		output += "  ~ " + change.Name + ": " + change.From.String() + " -> " + change.To.String() + "\n"
+	}
+	for _, dep := range diff.Removed {
+		output += "  - " + dep.Name() + "@" + dep.Version().String() + "\n"
+	}
+	return output
+}
@@ -125,3 +125,31 @@ func TestDependencySetUnmarshalJSON(t *testing.T) {
 		ds.AsMap()["github.com/b/b"],
 	)
 }
+
+func TestDependencySetDiff(t *testing.T) {
+	old := NewDependencySet().
+		AddDependency(NewDependency("github.com/a/a", NewVersion(SemVerRange, "^1.0.0"))).
+		AddDependency(NewDependency("github.com/b/b", NewVersion(Branch, "main"))).
+		AddDependency(NewDependency("github.com/c/c", NewVersion(Tag, "beta")))
+	updated := NewDependencySet().
+		AddDependency(NewDependency("github.com/a/a", NewVersion(SemVerRange, "^2.0.0"))).
+		AddDependency(NewDependency("github.com/b/b", NewVersion(Branch, "main"))).
+		AddDependency(NewDependency("github.com/d/d", NewVersion(SemVerExact, "1.0.0")))
+
+	diff := old.Diff(updated)
+
+	assert.Equal(t, []Dependency{NewDependency("github.com/d/d", NewVersion(SemVerExact, "1.0.0"))}, diff.Added)
+	assert.Equal(t, []VersionChange{{
+		Name: "github.com/a/a",
+		From: NewVersion(SemVerRange, "^1.0.0"),
+		To:   NewVersion(SemVerRange, "^2.0.0"),
+	}}, diff.Changed)
+	assert.Equal(t, []Dependency{NewDependency("github.com/c/c", NewVersion(Tag, "beta"))}, diff.Removed)
+	assert.False(t, diff.IsEmpty())
+	assert.True(t, old.Diff(old).IsEmpty())
+
+	expected := "  + github.com/d/d@1.0.0\n"
+	expected += "  ~ github.com/a/a: ^1.0.0 -> ^2.0.0\n"
+	expected += "  - github.com/c/c@tag:beta\n"
+	assert.Equal(t, expected, diff.String())
+}
@@ -0,0 +1,486 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// maxSolverAttempts limits how many candidate versions the solver will try
+// before giving up, since backtracking can take exponential time in the worst case
+const maxSolverAttempts = 10000
+
+// requirement records that a package, or the project itself, asked for a
+// particular version of a dependency
+type requirement struct {
+	// requester is the name and version of the package which has the
+	// dependency. It is empty for dependencies listed in the project's ahkpm.json
+	requester string
+	// requesterName is the name of the package which has the dependency
+	requesterName string
+	dep           Dependency
+}
+
+func (req requirement) String() string {
+	requester := req.requester
+	if requester == "" {
+		requester = "ahkpm.json"
+	}
+	return requester + " needs " + req.dep.Name() + "@" + req.dep.Version().String()
+}
+
+// selectedVersion is the version of a package chosen by the solver, along with
+// the commit it resolves to and the dependencies declared at that commit
+type selectedVersion struct {
+	version      Version
+	sha          string
+	dependencies DependencySet
+}
+
+// tag returns the name of the tag which was selected, or an empty string if
+// a branch or commit was selected
+func (sv selectedVersion) tag() string {
+	if isSemVerKind(sv.version.Kind()) || sv.version.Kind() == Tag {
+		return sv.version.Value()
+	}
+	return ""
+}
+
+// solverState is treated as immutable so that the solver can backtrack simply
+// by returning to a previous state
+type solverState struct {
+	requirements map[string][]requirement
+	selected     map[string]selectedVersion
+	// discovered lists package names in the order they were first required
+	discovered []string
+}
+
+type dependencySolver struct {
+	packagesRepository PackagesRepository
+	// concurrency is the maximum number of packages fetched at the same time
+	concurrency int
+	attempts    int
+
+	// mu guards the lookup caches, which are filled concurrently by prefetch
+	mu         sync.Mutex
+	tags       map[string]*lookup[[]string]
+	selections map[string]*lookup[selectedVersion]
+}
+
+// lookup holds the result of a repository lookup, which is performed at most
+// once even when several goroutines ask for it at the same time
+type lookup[T any] struct {
+	once  sync.Once
+	value T
+	err   error
+}
+
+func newDependencySolver(pr PackagesRepository, concurrency int) *dependencySolver {
+	return &dependencySolver{
+		packagesRepository: pr,
+		concurrency:        concurrency,
+		tags:               make(map[string]*lookup[[]string]),
+		selections:         make(map[string]*lookup[selectedVersion]),
+	}
+}
+
+// Solve selects a single version for every package in the dependency graph,
+// such that every requirement on that package is satisfied. If there is no
+// such selection, a *resolutionError is returned which explains why.
+func (s *dependencySolver) Solve(deps DependencySet) (map[string]selectedVersion, error) {
+	state := solverState{
+		requirements: make(map[string][]requirement),
+		selected:     make(map[string]selectedVersion),
+		discovered:   make([]string, 0),
+	}
+	for _, dep := range deps.AsArray() {
+		state = state.withRequirement(requirement{dep: dep})
+	}
+
+	s.prefetch(deps.AsArray())
+
+	return s.solve(state)
+}
+
+func (s *dependencySolver) solve(state solverState) (map[string]selectedVersion, error) {
+	name, ok := state.nextUnselected()
+	if !ok {
+		return state.selected, nil
+	}
+
+	requirements := state.requirements[name]
+	candidates, err := s.getCandidates(name, requirements)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, &resolutionError{name: name, requirements: requirements}
+	}
+
+	rejections := make([]rejection, 0, len(candidates))
+	for _, candidate := range candidates {
+		s.attempts++
+		if s.attempts > maxSolverAttempts {
+			return nil, errors.New("Unable to resolve dependencies. Too many possible combinations of versions.")
+		}
+
+		selection, err := s.getSelectedVersion(name, candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		// A cycle rules out the candidate before its dependencies are
+		// compared, since it would otherwise surface as a conflict between
+		// the versions required along it
+		if cycle := state.findCycle(name, selection); cycle != nil {
+			rejections = append(rejections, rejection{
+				version: name + "@" + candidate.String(),
+				reason:  &resolutionError{name: name, cycle: cycle},
+			})
+			continue
+		}
+
+		s.prefetch(selection.dependencies.AsArray())
+
+		nextState, conflict := state.withSelection(name, selection)
+		if conflict == nil {
+			selected, err := s.solve(nextState)
+			if err == nil {
+				return selected, nil
+			}
+			if !errors.As(err, &conflict) {
+				return nil, err
+			}
+		}
+
+		rejections = append(rejections, rejection{
+			version: name + "@" + candidate.String(),
+			reason:  conflict,
+		})
+	}
+
+	// When there was only one version to try, there's nothing to explain
+	// beyond the reason it was rejected
+	if len(rejections) == 1 {
+		return nil, rejections[0].reason
+	}
+
+	return nil, &resolutionError{name: name, rejected: rejections}
+}
+
+// getCandidates returns every version of the package which satisfies all of
+// the given requirements, ordered from most to least preferred
+func (s *dependencySolver) getCandidates(name string, requirements []requirement) ([]Version, error) {
+	// Branches, tags, commits and exact versions allow only a single candidate
+	for _, req := range requirements {
+		if req.dep.Version().Kind() != SemVerRange {
+			candidate := req.dep.Version()
+			if !satisfiesAll(candidate, requirements) {
+				return []Version{}, nil
+			}
+			return []Version{candidate}, nil
+		}
+	}
+
+	tags, err := s.getTags(name)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*semver.Version, 0)
+	for _, tag := range tags {
+		version, err := semver.StrictNewVersion(tag)
+		if err == nil && satisfiesAll(NewVersion(SemVerExact, tag), requirements) {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+
+	candidates := make([]Version, len(versions))
+	for i, version := range versions {
+		candidates[i] = NewVersion(SemVerExact, version.Original())
+	}
+	return candidates, nil
+}
+
+// prefetch looks up the given dependencies concurrently, so that their
+// repositories have already been fetched by the time the solver reaches them.
+// Errors are ignored here because the solver reports them when it performs
+// the same lookup itself.
+func (s *dependencySolver) prefetch(deps []Dependency) {
+	if s.concurrency < 2 {
+		return
+	}
+
+	semaphore := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for _, dep := range deps {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(dep Dependency) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			version := dep.Version()
+			if version.Kind() == SemVerRange {
+				candidates, err := s.getCandidates(dep.Name(), []requirement{{dep: dep}})
+				if err != nil || len(candidates) == 0 {
+					return
+				}
+				version = candidates[0]
+			}
+			_, _ = s.getSelectedVersion(dep.Name(), version)
+		}(dep)
+	}
+	wg.Wait()
+}
+
+func (s *dependencySolver) getTags(name string) ([]string, error) {
+	s.mu.Lock()
+	l, ok := s.tags[name]
+	if !ok {
+		l = &lookup[[]string]{}
+		s.tags[name] = l
+	}
+	s.mu.Unlock()
+
+	l.once.Do(func() {
+		l.value, l.err = s.packagesRepository.GetVersions(name)
+	})
+	return l.value, l.err
+}
+
+// getSelectedVersion looks up the commit and dependencies for a specific
+// version of a package
+func (s *dependencySolver) getSelectedVersion(name string, version Version) (selectedVersion, error) {
+	key := name + "@" + version.String()
+
+	s.mu.Lock()
+	l, ok := s.selections[key]
+	if !ok {
+		l = &lookup[selectedVersion]{}
+		s.selections[key] = l
+	}
+	s.mu.Unlock()
+
+	l.once.Do(func() {
+		l.value, l.err = s.lookupSelectedVersion(name, version)
+	})
+	return l.value, l.err
+}
+
+func (s *dependencySolver) lookupSelectedVersion(name string, version Version) (selectedVersion, error) {
+	sha, err := s.packagesRepository.GetResolvedDependencySHA(NewDependency(name, version))
+	if err != nil {
+		return selectedVersion{}, err
+	}
+
+	deps, err := s.packagesRepository.GetPackageDependencies(ResolvedDependency{
+		Name:    name,
+		Version: version.String(),
+		SHA:     sha,
+	})
+	if err != nil {
+		return selectedVersion{}, err
+	}
+
+	return selectedVersion{
+		version:      version,
+		sha:          sha,
+		dependencies: *deps,
+	}, nil
+}
+
+func (state solverState) nextUnselected() (string, bool) {
+	for _, name := range state.discovered {
+		if _, ok := state.selected[name]; !ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (state solverState) withRequirement(req requirement) solverState {
+	name := req.dep.Name()
+
+	requirements := make(map[string][]requirement, len(state.requirements)+1)
+	for k, v := range state.requirements {
+		requirements[k] = v
+	}
+	existing := state.requirements[name]
+	requirements[name] = append(existing[:len(existing):len(existing)], req)
+
+	discovered := state.discovered
+	if len(existing) == 0 {
+		discovered = append(discovered[:len(discovered):len(discovered)], name)
+	}
+
+	return solverState{
+		requirements: requirements,
+		selected:     state.selected,
+		discovered:   discovered,
+	}
+}
+
+// withSelection returns a new state in which the given version of the package
+// has been selected and its dependencies added as requirements. If one of
+// those dependencies conflicts with a version which has already been
+// selected, an error describing the conflict is returned instead.
+func (state solverState) withSelection(name string, selection selectedVersion) (solverState, *resolutionError) {
+	selected := make(map[string]selectedVersion, len(state.selected)+1)
+	for k, v := range state.selected {
+		selected[k] = v
+	}
+	selected[name] = selection
+
+	nextState := solverState{
+		requirements: state.requirements,
+		selected:     selected,
+		discovered:   state.discovered,
+	}
+
+	for _, dep := range selection.dependencies.AsArray() {
+		nextState = nextState.withRequirement(requirement{
+			requester:     name + "@" + selection.version.String(),
+			requesterName: name,
+			dep:           dep,
+		})
+
+		if existing, ok := selected[dep.Name()]; ok && !satisfies(existing.version, dep.Version()) {
+			return state, &resolutionError{
+				name:         dep.Name(),
+				requirements: nextState.requirements[dep.Name()],
+			}
+		}
+	}
+
+	return nextState, nil
+}
+
+// findCycle returns the chain of package names which would form a cycle if
+// the given version of the package were selected, or nil if there is none
+func (state solverState) findCycle(name string, selection selectedVersion) []string {
+	for _, dep := range selection.dependencies.AsArray() {
+		chain := state.getAncestorChain(dep.Name(), name, make(map[string]bool))
+		if chain != nil {
+			return append(chain, dep.Name())
+		}
+	}
+	return nil
+}
+
+// getAncestorChain returns the chain of package names from ancestor down to
+// name, following the requirements which led to each package, or nil if name
+// is not required by ancestor either directly or indirectly
+func (state solverState) getAncestorChain(ancestor string, name string, visited map[string]bool) []string {
+	if name == ancestor {
+		return []string{name}
+	}
+	visited[name] = true
+
+	for _, req := range state.requirements[name] {
+		if req.requesterName == "" || visited[req.requesterName] {
+			continue
+		}
+		chain := state.getAncestorChain(ancestor, req.requesterName, visited)
+		if chain != nil {
+			return append(chain, name)
+		}
+	}
+	return nil
+}
+
+func satisfiesAll(candidate Version, requirements []requirement) bool {
+	for _, req := range requirements {
+		if !satisfies(candidate, req.dep.Version()) {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfies returns true if the candidate version meets the required version.
+// Semantic versions are compared using ranges, while branches, tags and
+// commits must match exactly.
+func satisfies(candidate Version, required Version) bool {
+	if !isSemVerKind(required.Kind()) {
+		return candidate.Equals(required)
+	}
+
+	if candidate.Kind() != SemVerExact {
+		return false
+	}
+
+	constraint, err := semver.NewConstraint(required.Value())
+	if err != nil {
+		return false
+	}
+
+	version, err := semver.StrictNewVersion(candidate.Value())
+	if err != nil {
+		return false
+	}
+
+	return constraint.Check(version)
+}
+
+func isSemVerKind(kind VersionKind) bool {
+	return kind == SemVerExact || kind == SemVerRange
+}
+
+// resolutionError explains why no set of versions satisfies every requirement
+type resolutionError struct {
+	// name is the package for which no acceptable version was found
+	name string
+	// requirements is set when no version of the package satisfies all of them
+	requirements []requirement
+	// rejected is set when there were versions of the package to try, but
+	// each of them led to a conflict further down the dependency graph
+	rejected []rejection
+	// cycle is set when the package would depend on itself, and lists the
+	// names of the packages in the cycle
+	cycle []string
+}
+
+type rejection struct {
+	// version is the name and version of the package which was tried
+	version string
+	reason  *resolutionError
+}
+
+func (e *resolutionError) Error() string {
+	if len(e.cycle) > 0 {
+		return e.explain("")[0]
+	}
+	message := "Unable to resolve dependencies:\n" + strings.Join(e.explain("  "), "\n")
+	if offlineMode, _ := isOffline(); offlineMode {
+		message += "\nOnly versions already in the cache were considered, since ahkpm is in offline mode."
+	}
+	return message
+}
+
+func (e *resolutionError) explain(indent string) []string {
+	lines := make([]string, 0)
+
+	if len(e.cycle) > 0 {
+		return append(lines, indent+"Dependency cycle detected: "+strings.Join(e.cycle, " -> "))
+	}
+
+	if len(e.rejected) == 0 {
+		lines = append(lines, indent+"No version of "+e.name+" satisfies all requirements:")
+		for _, req := range e.requirements {
+			lines = append(lines, indent+"  "+req.String())
+		}
+		return lines
+	}
+
+	for _, r := range e.rejected {
+		lines = append(lines, indent+"Tried "+r.version+", but:")
+		lines = append(lines, r.reason.explain(indent+"  ")...)
+	}
+	return lines
+}
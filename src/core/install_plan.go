@@ -0,0 +1,107 @@
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// InstallPlan lists the changes needed to bring ahkpm-modules from one set of
+// resolved dependencies to another
+type InstallPlan struct {
+	// Remove lists install paths which are no longer needed
+	Remove []string
+	// Copy lists dependencies which must be (re)installed, parents first
+	Copy []ResolvedDependency
+	// Unchanged lists dependencies which are already installed correctly
+	Unchanged []ResolvedDependency
+}
+
+// PlanInstall compares the previously installed dependencies with the next
+// ones. A dependency is copied if it is new, its SHA or install path changed,
+// one of its ancestors is being copied, or isIntact reports that the
+// installed copy is missing or modified.
+func PlanInstall(
+	previous []ResolvedDependency,
+	next []ResolvedDependency,
+	isIntact func(dep ResolvedDependency) bool,
+) InstallPlan {
+	plan := InstallPlan{
+		Remove:    make([]string, 0),
+		Copy:      make([]ResolvedDependency, 0),
+		Unchanged: make([]ResolvedDependency, 0),
+	}
+
+	previousByPath := make(map[string]ResolvedDependency, len(previous))
+	for _, dep := range previous {
+		previousByPath[dep.InstallPath] = dep
+	}
+
+	nextPaths := make(map[string]bool, len(next))
+	for _, dep := range next {
+		nextPaths[dep.InstallPath] = true
+	}
+
+	// Sort parents before their children, since copying a parent replaces the
+	// ahkpm-modules directory containing its children
+	sorted := uniqueByInstallPath(next)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return len(sorted[a].InstallPath) < len(sorted[b].InstallPath)
+	})
+
+	copiedPaths := make([]string, 0)
+	for _, dep := range sorted {
+		prev, ok := previousByPath[dep.InstallPath]
+		isChanged := !ok || prev.Name != dep.Name || prev.SHA != dep.SHA
+		if !isChanged && dep.Integrity == "" {
+			// Newly resolved dependencies don't have a hash yet, but the
+			// installed copy should still match the previous one
+			dep.Integrity = prev.Integrity
+		}
+		if isChanged || isWithinAny(dep.InstallPath, copiedPaths) || !isIntact(dep) {
+			plan.Copy = append(plan.Copy, dep)
+			copiedPaths = append(copiedPaths, dep.InstallPath)
+		} else {
+			plan.Unchanged = append(plan.Unchanged, dep)
+		}
+	}
+
+	removedPaths := make([]string, 0)
+	for _, dep := range uniqueByInstallPath(previous) {
+		if nextPaths[dep.InstallPath] {
+			continue
+		}
+		if isWithinAny(dep.InstallPath, removedPaths) || isWithinAny(dep.InstallPath, copiedPaths) {
+			continue
+		}
+		plan.Remove = append(plan.Remove, dep.InstallPath)
+		removedPaths = append(removedPaths, dep.InstallPath)
+	}
+
+	return plan
+}
+
+// uniqueByInstallPath returns the dependencies with only the first one at each
+// install path. With the flat install layout, a package required by several
+// others is listed once for each of them but installed at a single path.
+func uniqueByInstallPath(deps []ResolvedDependency) []ResolvedDependency {
+	seen := make(map[string]bool, len(deps))
+	unique := make([]ResolvedDependency, 0, len(deps))
+	for _, dep := range deps {
+		if seen[dep.InstallPath] {
+			continue
+		}
+		seen[dep.InstallPath] = true
+		unique = append(unique, dep)
+	}
+	return unique
+}
+
+// isWithinAny returns true if the path is nested within any of the others
+func isWithinAny(path string, others []string) bool {
+	for _, other := range others {
+		if strings.HasPrefix(path, other+"/") {
+			return true
+		}
+	}
+	return false
+}
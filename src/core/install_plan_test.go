@@ -0,0 +1,62 @@
+package core_test
+
+import (
+	. "ahkpm/src/core"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanInstall(t *testing.T) {
+	depA := ResolvedDependency{Name: "github.com/a/a", SHA: "a1", InstallPath: "ahkpm-modules/github.com/a/a", Integrity: "sha256-a1"}
+	depAChild := ResolvedDependency{Name: "github.com/c/c", SHA: "c1", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c", Integrity: "sha256-c1"}
+	depB := ResolvedDependency{Name: "github.com/b/b", SHA: "b1", InstallPath: "ahkpm-modules/github.com/b/b", Integrity: "sha256-b1"}
+	depBChild := ResolvedDependency{Name: "github.com/c/c", SHA: "c1", InstallPath: "ahkpm-modules/github.com/b/b/ahkpm-modules/github.com/c/c", Integrity: "sha256-c1"}
+	depD := ResolvedDependency{Name: "github.com/d/d", SHA: "d1", InstallPath: "ahkpm-modules/github.com/d/d", Integrity: "sha256-d1"}
+	depDChild := ResolvedDependency{Name: "github.com/e/e", SHA: "e1", InstallPath: "ahkpm-modules/github.com/d/d/ahkpm-modules/github.com/e/e", Integrity: "sha256-e1"}
+
+	// b changes commit, d is removed, and f is added
+	newDepB := ResolvedDependency{Name: "github.com/b/b", SHA: "b2", InstallPath: "ahkpm-modules/github.com/b/b"}
+	// c is freshly resolved, so has no hash of its own
+	newDepAChild := ResolvedDependency{Name: "github.com/c/c", SHA: "c1", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c"}
+	depF := ResolvedDependency{Name: "github.com/f/f", SHA: "f1", InstallPath: "ahkpm-modules/github.com/f/f"}
+
+	previous := []ResolvedDependency{depA, depAChild, depB, depBChild, depD, depDChild}
+	next := []ResolvedDependency{depA, newDepAChild, newDepB, depBChild, depF}
+
+	plan := PlanInstall(previous, next, func(dep ResolvedDependency) bool { return true })
+
+	assert.Equal(t, []string{"ahkpm-modules/github.com/d/d"}, plan.Remove)
+	assert.Equal(t, []ResolvedDependency{newDepB, depF, depBChild}, plan.Copy)
+	assert.Equal(t, []ResolvedDependency{depA, depAChild}, plan.Unchanged)
+}
+
+func TestPlanInstallWithModifiedPackage(t *testing.T) {
+	depA := ResolvedDependency{Name: "github.com/a/a", SHA: "a1", InstallPath: "ahkpm-modules/github.com/a/a", Integrity: "sha256-a1"}
+	depAChild := ResolvedDependency{Name: "github.com/c/c", SHA: "c1", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c", Integrity: "sha256-c1"}
+	depB := ResolvedDependency{Name: "github.com/b/b", SHA: "b1", InstallPath: "ahkpm-modules/github.com/b/b", Integrity: "sha256-b1"}
+	resolved := []ResolvedDependency{depA, depAChild, depB}
+
+	plan := PlanInstall(resolved, resolved, func(dep ResolvedDependency) bool {
+		return dep.Name != "github.com/a/a"
+	})
+
+	assert.Equal(t, []string{}, plan.Remove)
+	assert.Equal(t, []ResolvedDependency{depA, depAChild}, plan.Copy)
+	assert.Equal(t, []ResolvedDependency{depB}, plan.Unchanged)
+}
+
+func TestPlanInstallWithSharedInstallPath(t *testing.T) {
+	depA := ResolvedDependency{Name: "github.com/a/a", SHA: "a1", InstallPath: "ahkpm-modules/github.com/a/a"}
+	depB := ResolvedDependency{Name: "github.com/b/b", SHA: "b1", InstallPath: "ahkpm-modules/github.com/b/b"}
+	// With the flat install layout, c is listed once for each package which
+	// requires it, but only installed once
+	depC := ResolvedDependency{Name: "github.com/c/c", SHA: "c1", InstallPath: "ahkpm-modules/github.com/c/c", RequiredBy: []string{"github.com/a/a"}}
+	depCForB := ResolvedDependency{Name: "github.com/c/c", SHA: "c1", InstallPath: "ahkpm-modules/github.com/c/c", RequiredBy: []string{"github.com/b/b"}}
+
+	plan := PlanInstall([]ResolvedDependency{}, []ResolvedDependency{depA, depC, depB, depCForB}, func(dep ResolvedDependency) bool { return true })
+
+	assert.Equal(t, []string{}, plan.Remove)
+	assert.Equal(t, []ResolvedDependency{depA, depC, depB}, plan.Copy)
+	assert.Equal(t, []ResolvedDependency{}, plan.Unchanged)
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"ahkpm/src/utils"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// installTransaction applies changes to ahkpm-modules, ahkpm.json and
+// ahkpm.lock so that they can all be rolled back if any of them fails.
+// Packages are first copied into a staging directory and then moved into
+// place, while anything they replace is moved into a backup directory.
+type installTransaction struct {
+	stagingDir string
+	backupDir  string
+	// undo holds a function for each change applied so far, which reverses it
+	undo []func() error
+}
+
+func newInstallTransaction() (*installTransaction, error) {
+	// The directories are created in the project directory rather than the
+	// system's temp directory so that packages can be moved with a rename
+	stagingDir, err := os.MkdirTemp(".", ".ahkpm-staging-")
+	if err != nil {
+		return nil, err
+	}
+	backupDir, err := os.MkdirTemp(".", ".ahkpm-backup-")
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		return nil, err
+	}
+
+	return &installTransaction{
+		stagingDir: stagingDir,
+		backupDir:  backupDir,
+		undo:       make([]func() error, 0),
+	}, nil
+}
+
+// stagingPath returns where a package should be copied before it is moved to
+// its install path
+func (tx *installTransaction) stagingPath(installPath string) string {
+	return filepath.Join(tx.stagingDir, installPath)
+}
+
+// replace moves the staged copy of a package to its install path, backing up
+// whatever was there before
+func (tx *installTransaction) replace(installPath string) error {
+	err := tx.remove(installPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(installPath), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tx.stagingPath(installPath), installPath)
+	if err != nil {
+		return err
+	}
+	tx.undo = append(tx.undo, func() error {
+		return os.RemoveAll(installPath)
+	})
+
+	return nil
+}
+
+// remove moves the directory at the install path to the backup directory, if
+// it exists
+func (tx *installTransaction) remove(installPath string) error {
+	exists, err := utils.FileExists(installPath)
+	if err != nil || !exists {
+		return err
+	}
+
+	backupPath := filepath.Join(tx.backupDir, installPath)
+	err = os.MkdirAll(filepath.Dir(backupPath), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(installPath, backupPath)
+	if err != nil {
+		return err
+	}
+	tx.undo = append(tx.undo, func() error {
+		err := os.MkdirAll(filepath.Dir(installPath), 0755)
+		if err != nil {
+			return err
+		}
+		return os.Rename(backupPath, installPath)
+	})
+
+	return nil
+}
+
+// writeJson atomically replaces the file with the value as JSON, keeping the
+// previous contents in case of a rollback
+func (tx *installTransaction) writeJson(path string, value any) error {
+	jsonBytes, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	previous, err := os.ReadFile(path)
+	hadPrevious := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	err = utils.WriteFileAtomic(path, jsonBytes, 0644)
+	if err != nil {
+		return err
+	}
+	tx.undo = append(tx.undo, func() error {
+		if !hadPrevious {
+			return os.Remove(path)
+		}
+		return utils.WriteFileAtomic(path, previous, 0644)
+	})
+
+	return nil
+}
+
+// commit removes the staging and backup directories, making the changes
+// permanent
+func (tx *installTransaction) commit() error {
+	err := os.RemoveAll(tx.stagingDir)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(tx.backupDir)
+}
+
+// rollback reverses every change in the opposite order it was applied, then
+// cleans up. It returns the first error encountered, but keeps going so that
+// as much as possible is restored.
+func (tx *installTransaction) rollback() error {
+	var firstErr error
+	for j := len(tx.undo) - 1; j >= 0; j-- {
+		err := tx.undo[j]()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	tx.undo = nil
+
+	// Keep the backups if anything could not be restored from them
+	if firstErr != nil {
+		os.RemoveAll(tx.stagingDir)
+		return errors.New("Unable to restore all changes. Backups were kept in " + tx.backupDir + ": " + firstErr.Error())
+	}
+	return tx.commit()
+}
@@ -4,42 +4,85 @@ import (
 	"ahkpm/src/utils"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
-type Installer struct{}
+type Installer struct {
+	// Concurrency is the maximum number of packages fetched at once while
+	// resolving dependencies. If zero, DefaultConcurrency is used.
+	Concurrency int
+}
 
 func (i Installer) Install(newDeps DependencySet) {
-	pr := NewPackagesRepository()
-
 	lm, err := LockManifestFromCwd()
-	if err == nil && newDeps.Len() == 0 {
+	hasLockfile := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// Never overwrite a lockfile which could not be read
+		utils.Exit(err.Error())
+	}
+
+	manifest := ManifestFromCwd()
+
+	// Find any changes made to ahkpm.json since the lockfile was written
+	drift := DependencySetDiff{}
+	if hasLockfile {
+		drift = lm.Dependencies.Diff(manifest.Dependencies)
+	}
+
+	if hasLockfile && newDeps.Len() == 0 && drift.IsEmpty() {
 		fmt.Println("No dependency changes found. Installing from lockfile.")
-		os.RemoveAll("ahkpm-modules")
-		for _, resolvedDep := range lm.Resolved {
-			err := pr.CopyPackage(resolvedDep, resolvedDep.InstallPath)
-			if err != nil {
-				utils.Exit(err.Error())
-			}
+		isLayoutChanged, layoutErr := isInstallLayoutChanged(lm.Resolved, manifest.InstallLayout)
+		if layoutErr != nil {
+			utils.Exit(layoutErr.Error())
+		}
+		if lm.MigratedFrom != "" {
+			fmt.Println("Updating ahkpm.lock from lockfile version " + lm.MigratedFrom + ".")
+			err = i.apply(lm.Resolved, ResolvedDependencyTreeFromArray(lm.Resolved), nil, lm.Dependencies)
+		} else if isLayoutChanged {
+			fmt.Println("Moving dependencies to match the install layout in ahkpm.json.")
+			err = i.apply(lm.Resolved, ResolvedDependencyTreeFromArray(lm.Resolved), nil, lm.Dependencies)
+		} else {
+			err = i.copyFromLockfile(*lm)
+		}
+		if err != nil {
+			utils.Exit(err.Error())
 		}
 
 		fmt.Println("Installation complete.")
 		return
 	}
-	hasLockfile := err == nil
 
-	manifest := ManifestFromCwd()
+	if !drift.IsEmpty() {
+		fmt.Println("ahkpm.json has changed since ahkpm.lock was last updated:")
+		fmt.Print(drift.String())
+	}
 
 	// If there is no lockfile, we need to resolve all dependencies, not just
-	// the new ones.
+	// the new ones. Otherwise only new and changed dependencies are resolved.
 	deps := newDeps
 	if !hasLockfile {
 		for _, dep := range manifest.Dependencies.AsArray() {
 			deps.AddDependency(dep)
 		}
 	}
+	for _, dep := range drift.Added {
+		if !deps.Contains(dep.Name()) {
+			deps.AddDependency(dep)
+		}
+	}
+	for _, change := range drift.Changed {
+		if !deps.Contains(change.Name) {
+			deps.AddDependency(NewDependency(change.Name, change.To))
+		}
+	}
 
-	resolver := NewDependencyResolver()
+	resolver := i.newResolver()
 	resolvedDepTree, err := resolver.Resolve(deps)
 	if err != nil {
 		utils.Exit(err.Error())
@@ -47,28 +90,67 @@ func (i Installer) Install(newDeps DependencySet) {
 
 	var combinedDepTree ResolvedDependencyTree
 	if hasLockfile {
-		oldDepTree := ResolvedDependencyTreeFromArray(lm.Resolved)
+		removedNames := make([]string, 0)
+		for _, dep := range drift.Removed {
+			removedNames = append(removedNames, dep.Name())
+		}
+		oldDepTree := ResolvedDependencyTreeFromArray(lm.Resolved).RemoveTopLevelDependencies(removedNames)
 		combinedDepTree = oldDepTree.Merge(resolvedDepTree)
 	} else {
 		combinedDepTree = resolvedDepTree
 	}
 
 	err = combinedDepTree.CheckForConflicts()
+	if err != nil && hasLockfile {
+		// Overlapping ranges can only be unified when they are resolved
+		// together, so retry with every dependency instead of just the new ones
+		allDeps := NewDependencySet().
+			AddDependencies(manifest.Dependencies.AsArray()).
+			AddDependencies(newDeps.AsArray())
+		combinedDepTree, err = resolver.Resolve(allDeps)
+	}
 	if err != nil {
 		utils.Exit(err.Error())
 	}
 
-	i.copyResolved(combinedDepTree)
-
+	previous := make([]ResolvedDependency, 0)
+	if hasLockfile {
+		previous = lm.Resolved
+		combinedDepTree = combinedDepTree.KeepDeduped(previous)
+	}
 	manifest.Dependencies.AddDependencies(newDeps.AsArray())
-	manifest.SaveToCwd()
+	err = i.apply(previous, combinedDepTree, manifest, manifest.Dependencies)
+	if err != nil {
+		utils.Exit(err.Error())
+	}
+
+	fmt.Println("Installation complete.")
+}
+
+// InstallFrozen installs exactly what is in ahkpm.lock without resolving
+// anything. It fails if the lockfile does not match the dependencies in
+// ahkpm.json, and never writes to either file.
+func (i Installer) InstallFrozen() error {
+	lm, err := LockManifestFromCwd()
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.New("ahkpm.lock not found in current directory. A lockfile is required to install with a frozen lockfile.")
+	}
+	if err != nil {
+		return err
+	}
+
+	manifest := ManifestFromCwd()
+	if !lm.Dependencies.Equals(manifest.Dependencies) {
+		return errors.New("ahkpm.lock is out of date with ahkpm.json. Run `ahkpm install` to update it.")
+	}
 
-	NewLockManifest().
-		WithDependencies(manifest.Dependencies).
-		WithResolved(combinedDepTree).
-		SaveToCwd()
+	err = i.copyFromLockfile(*lm)
+	if err != nil {
+		return err
+	}
 
 	fmt.Println("Installation complete.")
+	return nil
 }
 
 func (i Installer) Uninstall(depNames []string) {
@@ -82,79 +164,464 @@ func (i Installer) Uninstall(depNames []string) {
 
 	resolvedDepTree := ResolvedDependencyTreeFromArray(lm.Resolved).RemoveTopLevelDependencies(depNames)
 
-	i.copyResolved(resolvedDepTree)
+	err = i.apply(lm.Resolved, resolvedDepTree, manifest, manifest.Dependencies)
+	if err != nil {
+		utils.Exit(err.Error())
+	}
+
+	fmt.Println("Uninstallation complete.")
+}
 
-	manifest.SaveToCwd()
+// Update reinstalls the packages in wantedNames at the newest versions allowed
+// by ahkpm.json, and moves the ranges of the packages in latestNames to their
+// latest versions, even across major versions. All of them are resolved and
+// installed together, while every other package keeps its locked version.
+func (i Installer) Update(wantedNames []string, latestNames []string) error {
+	manifest := ManifestFromCwd()
 
-	NewLockManifest().
-		WithDependencies(manifest.Dependencies).
-		WithResolved(resolvedDepTree).
-		SaveToCwd()
+	wantedDeps, err := getManifestDependencies(manifest, wantedNames)
+	if err != nil {
+		return err
+	}
+	latestDeps, err := getManifestDependencies(manifest, latestNames)
+	if err != nil {
+		return err
+	}
 
-	fmt.Println("Uninstallation complete.")
+	changes, err := getLatestVersionChanges(NewPackagesRepository(), latestDeps)
+	if err != nil {
+		return err
+	}
+	if len(latestDeps) > 0 && len(changes) == 0 {
+		fmt.Println("ahkpm.json already allows the latest versions.")
+	}
+
+	changedDeps := NewDependencySet().AddDependencies(wantedDeps)
+	for _, dep := range latestDeps {
+		changeIndex := slices.IndexFunc(changes, func(change VersionChange) bool {
+			return change.Name == dep.Name()
+		})
+		if changeIndex < 0 {
+			// The range already allows the latest version, so the package
+			// only needs to be updated within it
+			changedDeps.AddDependency(dep)
+			continue
+		}
+		changedDep := NewDependency(dep.Name(), changes[changeIndex].To)
+		manifest.Dependencies.AddDependency(changedDep)
+		changedDeps.AddDependency(changedDep)
+	}
+	if changedDeps.Len() != len(wantedNames)+len(latestNames) {
+		return errors.New("Cannot update multiple versions of the same package")
+	}
+
+	if len(changes) > 0 {
+		printVersionChanges(changes)
+	}
+
+	previous := make([]ResolvedDependency, 0)
+	lockDeps := manifest.Dependencies
+	var resolvedDepTree ResolvedDependencyTree
+	lm, err := LockManifestFromCwd()
+	if err == nil {
+		// Only the changed packages move, while the rest stay locked
+		previous = lm.Resolved
+		lockDeps = NewDependencySet().AddDependencies(lm.Dependencies.AsArray())
+		for _, change := range changes {
+			lockDeps.AddDependency(NewDependency(change.Name, change.To))
+		}
+		resolvedDepTree, err = ResolveUpdates(i.newResolver(), lm.Resolved, changedDeps, manifest.Dependencies)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		resolvedDepTree, err = i.newResolver().Resolve(manifest.Dependencies)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Leave ahkpm.json untouched when no ranges changed
+	manifestToSave := manifest
+	if len(changes) == 0 {
+		manifestToSave = nil
+	}
+	return i.apply(previous, resolvedDepTree, manifestToSave, lockDeps)
+}
+
+// ResolveUpdates resolves only the changed dependencies and puts them in place
+// of the same packages in the locked tree, so that every other package keeps
+// its locked version. If that leaves conflicting versions of a package, every
+// dependency in allDeps is resolved together instead. Packages which were
+// deduped stay deduped as long as they remain at the same commit.
+func ResolveUpdates(
+	resolver DependencyResolver,
+	locked []ResolvedDependency,
+	changedDeps DependencySet,
+	allDeps DependencySet,
+) (ResolvedDependencyTree, error) {
+	updated, err := resolver.Resolve(changedDeps)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace subtrees with new resolved deps
+	merged := ResolvedDependencyTreeFromArray(locked)
+	for _, updatedNode := range updated {
+		index := slices.IndexFunc(merged, func(n TreeNode[ResolvedDependency]) bool {
+			return n.Value.Name == updatedNode.Value.Name
+		})
+		if index >= 0 {
+			merged[index] = updatedNode
+		} else {
+			merged = append(merged, updatedNode)
+		}
+	}
+
+	err = merged.CheckForConflicts()
+	if err != nil {
+		// Overlapping ranges can only be unified when they are resolved
+		// together, so retry with every dependency instead of just the updated ones
+		merged, err = resolver.Resolve(allDeps)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return merged.KeepDeduped(locked), nil
 }
 
-func (i Installer) Update(packageNames ...string) error {
-	depsToUpdate := NewDependencySet()
-	currentDeps := ManifestFromCwd().Dependencies.AsMap()
+// getManifestDependencies returns the dependencies in ahkpm.json with the
+// given names
+func getManifestDependencies(manifest *Manifest, packageNames []string) ([]Dependency, error) {
+	currentDeps := manifest.Dependencies.AsMap()
+
+	deps := make([]Dependency, 0, len(packageNames))
 	for _, packageName := range packageNames {
 		packageName = CanonicalizeDependencyName(packageName)
 
 		dep, ok := currentDeps[packageName]
 		if !ok {
-			return fmt.Errorf("Cannot update %s. It is not present in ahkpm.json", packageName)
+			return nil, fmt.Errorf("Cannot update %s. It is not present in ahkpm.json", packageName)
 		}
-		depsToUpdate.AddDependency(dep)
+		deps = append(deps, dep)
 	}
-	if depsToUpdate.Len() != len(packageNames) {
-		return errors.New("Cannot update multiple versions of the same package")
+	return deps, nil
+}
+
+// getLatestVersionChanges returns how the ranges of the dependencies would
+// change if they were moved to the latest versions of their packages
+func getLatestVersionChanges(pr PackagesRepository, deps []Dependency) ([]VersionChange, error) {
+	changes := make([]VersionChange, 0)
+	for _, dep := range deps {
+		if !isSemVerKind(dep.Version().Kind()) {
+			fmt.Printf("Skipping %s, which is not on a semantic version\n", dep.Name())
+			continue
+		}
+
+		latest, err := pr.GetLatestVersion(dep.Name())
+		if err != nil {
+			return nil, err
+		}
+		if latest.Kind() != SemVerExact {
+			continue
+		}
+
+		newVersion := ToLatestVersion(dep.Version(), latest.Value())
+		if !newVersion.Equals(dep.Version()) {
+			changes = append(changes, VersionChange{Name: dep.Name(), From: dep.Version(), To: newVersion})
+		}
 	}
+	return changes, nil
+}
 
+func printVersionChanges(changes []VersionChange) {
+	fmt.Println("Updating ahkpm.json:")
+	for _, change := range changes {
+		fmt.Printf("  %s: %s -> %s\n", change.Name, change.From.String(), change.To.String())
+	}
+
+	majorChanges := make([]VersionChange, 0)
+	for _, change := range changes {
+		if change.IsMajor() {
+			majorChanges = append(majorChanges, change)
+		}
+	}
+	if len(majorChanges) > 0 {
+		fmt.Println("\nThe following updates cross major versions and may include breaking changes:")
+		for _, change := range majorChanges {
+			fmt.Printf("  %s: %s -> %s\n", change.Name, change.From.String(), change.To.String())
+		}
+	}
+}
+
+func (i Installer) newResolver() DependencyResolver {
 	resolver := NewDependencyResolver()
-	newResolvedDepTree, err := resolver.Resolve(depsToUpdate)
+	if i.Concurrency > 0 {
+		resolver = resolver.WithConcurrency(i.Concurrency)
+	}
+	return resolver
+}
+
+// copyFromLockfile installs each resolved dependency in the lockfile at its
+// locked SHA and install path, without saving any files. Packages which are
+// already installed correctly are left alone.
+func (i Installer) copyFromLockfile(lm LockManifest) error {
+	pr, err := newInstallingPackagesRepository(ManifestFromCwd())
 	if err != nil {
 		return err
 	}
 
-	// Get tree from lockfile
-	lm, err := LockManifestFromCwd()
+	tx, err := newInstallTransaction()
 	if err != nil {
 		return err
 	}
-	oldResolved := ResolvedDependencyTreeFromArray(lm.Resolved)
 
-	// Replace subtrees with new resolved deps
-	for _, resolvedDepNode := range newResolvedDepTree {
-		for i, oldResolvedDepNode := range oldResolved {
-			if resolvedDepNode.Value.Name == oldResolvedDepNode.Value.Name {
-				oldResolved[i] = resolvedDepNode
-			}
-		}
+	plan := PlanInstall(lm.Resolved, lm.Resolved, isInstalledIntact)
+	_, err = applyInstallPlan(tx, pr, plan)
+	if err != nil {
+		return rollbackAfter(tx, err)
 	}
 
-	err = oldResolved.CheckForConflicts()
+	return tx.commit()
+}
+
+// apply updates ahkpm-modules from the previously installed dependencies to
+// the resolved tree, then saves ahkpm.json (unless manifest is nil) and
+// ahkpm.lock. If any step fails, all of them are rolled back.
+func (i Installer) apply(
+	previous []ResolvedDependency,
+	resolved ResolvedDependencyTree,
+	manifest *Manifest,
+	lockDeps DependencySet,
+) error {
+	saveManifest := manifest != nil
+	if !saveManifest {
+		manifest = ManifestFromCwd()
+	}
+	resolved, err := resolved.WithInstallLayout(manifest.InstallLayout)
+	if err != nil {
+		return err
+	}
+	pr, err := newInstallingPackagesRepository(manifest)
+	if err != nil {
+		return err
+	}
+
+	tx, err := newInstallTransaction()
 	if err != nil {
 		return err
 	}
 
-	i.copyResolved(oldResolved)
+	installed, err := copyResolved(tx, pr, previous, resolved)
+	if err != nil {
+		return rollbackAfter(tx, err)
+	}
 
-	// Save lockfile
-	NewLockManifest().
-		WithDependencies(lm.Dependencies).
-		WithResolved(oldResolved).
-		SaveToCwd()
+	if saveManifest {
+		err = tx.writeJson("ahkpm.json", manifest)
+		if err != nil {
+			return rollbackAfter(tx, err)
+		}
+	}
 
-	return nil
+	lm := NewLockManifest().
+		WithDependencies(lockDeps).
+		WithResolved(installed)
+	err = tx.writeJson("ahkpm.lock", lm)
+	if err != nil {
+		return rollbackAfter(tx, err)
+	}
+
+	return tx.commit()
 }
 
-func (i Installer) copyResolved(resolved ResolvedDependencyTree) {
-	os.RemoveAll("ahkpm-modules")
-	err := resolved.ForEach(func(resolvedDepNode TreeNode[ResolvedDependency]) error {
-		resolvedDep := resolvedDepNode.Value
-		return NewPackagesRepository().CopyPackage(resolvedDep, resolvedDep.InstallPath)
-	})
+// isInstallLayoutChanged returns true if the resolved dependencies in the
+// lockfile would be installed at different paths with the given layout
+func isInstallLayoutChanged(resolved []ResolvedDependency, layout string) (bool, error) {
+	relaidOut, err := ResolvedDependencyTreeFromArray(resolved).WithInstallLayout(layout)
 	if err != nil {
-		utils.Exit(err.Error())
+		return false, err
+	}
+
+	getPlacements := func(deps []ResolvedDependency) map[string]bool {
+		placements := make(map[string]bool, len(deps))
+		for _, dep := range deps {
+			placements[strings.Join(dep.RequiredBy, " ")+" "+dep.Name+" "+dep.InstallPath] = true
+		}
+		return placements
+	}
+
+	return !maps.Equal(getPlacements(resolved), getPlacements(relaidOut.Flatten())), nil
+}
+
+// newInstallingPackagesRepository returns a packages repository which installs
+// packages using the installMode in the manifest
+func newInstallingPackagesRepository(manifest *Manifest) (PackagesRepository, error) {
+	switch manifest.InstallMode {
+	case "", CopyInstallMode, SymlinkInstallMode, HardlinkInstallMode:
+		return NewPackagesRepository().WithInstallMode(manifest.InstallMode), nil
+	}
+	return nil, fmt.Errorf(
+		"Invalid installMode %q in ahkpm.json. Expected %q, %q or %q.",
+		manifest.InstallMode, CopyInstallMode, SymlinkInstallMode, HardlinkInstallMode,
+	)
+}
+
+// rollbackAfter rolls back the transaction after an error, returning the
+// original error along with any error from the rollback itself
+func rollbackAfter(tx *installTransaction, err error) error {
+	rollbackErr := tx.rollback()
+	if rollbackErr != nil {
+		return errors.New(err.Error() + "\n" + rollbackErr.Error())
+	}
+	return errors.New(err.Error() + "\nNo changes were made.")
+}
+
+// copyResolved updates ahkpm-modules from the previously installed
+// dependencies to the resolved tree, only copying and removing the packages
+// which changed. It returns the tree with the integrity hash and resolution
+// time recorded for any newly resolved dependencies.
+func copyResolved(
+	tx *installTransaction,
+	pr PackagesRepository,
+	previous []ResolvedDependency,
+	resolved ResolvedDependencyTree,
+) (ResolvedDependencyTree, error) {
+	plan := PlanInstall(previous, resolved.Flatten(), isInstalledIntact)
+
+	installed, err := applyInstallPlan(tx, pr, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep what was recorded when unchanged packages were last installed
+	previousByPath := make(map[string]ResolvedDependency, len(previous))
+	for _, dep := range previous {
+		previousByPath[dep.InstallPath] = dep
+	}
+
+	resolvedAt := time.Now().UTC().Format(time.RFC3339)
+	return resolved.Map(func(resolvedDepNode TreeNode[ResolvedDependency]) TreeNode[ResolvedDependency] {
+		dep := resolvedDepNode.Value
+		installedDep := installed[dep.InstallPath]
+		dep.Integrity = installedDep.Integrity
+		dep.ResolvedAt = installedDep.ResolvedAt
+		prev, ok := previousByPath[dep.InstallPath]
+		isSameCommit := ok && prev.Name == dep.Name && prev.SHA == dep.SHA
+		if dep.ResolvedAt == "" && isSameCommit {
+			dep.ResolvedAt = prev.ResolvedAt
+		}
+		if dep.ResolvedAt == "" {
+			dep.ResolvedAt = resolvedAt
+		}
+		resolvedDepNode.Value = dep
+		return resolvedDepNode
+	}), nil
+}
+
+// applyInstallPlan copies the planned packages into the staging directory,
+// then moves them into place and removes packages which are no longer needed.
+// It returns every dependency in the plan by install path, with integrity
+// hashes recorded.
+func applyInstallPlan(tx *installTransaction, pr PackagesRepository, plan InstallPlan) (map[string]ResolvedDependency, error) {
+	installed := make(map[string]ResolvedDependency, len(plan.Copy)+len(plan.Unchanged))
+
+	// Copy everything first, since this is the step most likely to fail
+	copiedPaths := make([]string, 0)
+	for _, dep := range plan.Copy {
+		installedDep, err := installPackage(pr, dep, tx.stagingPath(dep.InstallPath))
+		if err != nil {
+			return nil, err
+		}
+		installed[dep.InstallPath] = installedDep
+		copiedPaths = append(copiedPaths, dep.InstallPath)
+	}
+
+	for _, path := range plan.Remove {
+		err := tx.remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Packages nested in a copied package were staged inside it, so only the
+	// outermost copied packages need to be moved
+	for _, path := range copiedPaths {
+		if isWithinAny(path, copiedPaths) {
+			continue
+		}
+		err := tx.replace(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, dep := range plan.Unchanged {
+		installed[dep.InstallPath] = dep
+	}
+
+	err := removeEmptyModuleDirectories("ahkpm-modules")
+	if err != nil {
+		return nil, err
+	}
+
+	return installed, nil
+}
+
+// isInstalledIntact returns true if the installed files of the dependency
+// match its integrity hash. Without a hash there is no way to tell whether
+// the files were modified, so the dependency is treated as not intact.
+func isInstalledIntact(dep ResolvedDependency) bool {
+	if dep.Integrity == "" {
+		return false
+	}
+	exists, err := utils.FileExists(dep.InstallPath)
+	if err != nil || !exists {
+		return false
+	}
+	integrity, err := utils.HashDirectory(dep.InstallPath, ".git", "ahkpm-modules")
+	return err == nil && integrity == dep.Integrity
+}
+
+// installPackage copies the package to the path and checks the hash of the
+// copied files against the one recorded in the lockfile. If they don't match,
+// the cached files may have been modified, for example through a link, so
+// they are extracted again from the package's repository and installed once
+// more. If no hash was recorded, it is added to the returned dependency.
+func installPackage(pr PackagesRepository, dep ResolvedDependency, path string) (ResolvedDependency, error) {
+	integrity, err := copyPackageAndHash(pr, dep, path)
+	if err != nil {
+		return dep, err
+	}
+
+	if dep.Integrity != "" && dep.Integrity != integrity {
+		err = pr.ReextractPackage(dep)
+		if err != nil {
+			return dep, err
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return dep, err
+		}
+		integrity, err = copyPackageAndHash(pr, dep, path)
+		if err != nil {
+			return dep, err
+		}
+	}
+
+	if dep.Integrity != "" && dep.Integrity != integrity {
+		return dep, fmt.Errorf(
+			"Integrity check failed for %s@%s. Expected %s but the installed files hash to %s, "+
+				"even after extracting them again from the cache. Run `ahkpm cache verify` to check the cache for corruption.",
+			dep.Name, dep.Version, dep.Integrity, integrity,
+		)
+	}
+
+	dep.Integrity = integrity
+	return dep, nil
+}
+
+func copyPackageAndHash(pr PackagesRepository, dep ResolvedDependency, path string) (string, error) {
+	err := pr.CopyPackage(dep, path)
+	if err != nil {
+		return "", err
 	}
+	return utils.HashDirectory(path, ".git", "ahkpm-modules")
 }
@@ -0,0 +1,106 @@
+package core_test
+
+import (
+	. "ahkpm/src/core"
+	"ahkpm/src/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestResolveUpdatesKeepsOtherDependenciesLocked(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	resolver := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	locked := []ResolvedDependency{
+		{
+			Name:         "github.com/a/a",
+			Version:      "^1.0.0",
+			Tag:          "1.0.0",
+			SHA:          "a-1.0.0",
+			InstallPath:  "ahkpm-modules/github.com/a/a",
+			Dependencies: NewDependencySet(),
+		},
+		{
+			Name:         "github.com/b/b",
+			Version:      "^1.0.0",
+			Tag:          "1.0.0",
+			SHA:          "b-1.0.0",
+			InstallPath:  "ahkpm-modules/github.com/b/b",
+			Dependencies: NewDependencySet(),
+		},
+	}
+	updatedA := NewDependency("github.com/a/a", NewVersion(SemVerRange, "^2.0.0"))
+	changedDeps := NewDependencySet().AddDependency(updatedA)
+	allDeps := NewDependencySet().
+		AddDependency(updatedA).
+		AddDependency(NewDependency("github.com/b/b", NewVersion(SemVerRange, "^1.0.0")))
+	emptySet := NewDependencySet()
+
+	mockPR.On("GetVersions", "github.com/a/a").Return([]string{"1.0.0", "2.0.0"}, nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/a/a", NewVersion(SemVerExact, "2.0.0"))).Return("a-2.0.0", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == "github.com/a/a"
+	})).Return(&emptySet, nil)
+
+	tree, err := ResolveUpdates(resolver, locked, changedDeps, allDeps)
+
+	assert.NoError(t, err)
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "a-2.0.0", tree[0].Value.SHA)
+	assert.Equal(t, "^2.0.0", tree[0].Value.Version)
+	assert.Equal(t, "b-1.0.0", tree[1].Value.SHA)
+	assert.Equal(t, "1.0.0", tree[1].Value.Tag)
+	mockPR.AssertNotCalled(t, "GetVersions", "github.com/b/b")
+}
+
+func TestResolveUpdatesKeepsDedupedCopiesInPlace(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	resolver := NewDependencyResolver().WithPackagesRepository(mockPR).WithConcurrency(1)
+	depC := NewDependency("github.com/c/c", NewVersion(SemVerRange, "^1.0.0"))
+	cDeps := NewDependencySet().AddDependency(depC)
+	emptySet := NewDependencySet()
+
+	// x and y both need c, which dedupe moves to the top level
+	tree := ResolvedDependencyTreeFromArray([]ResolvedDependency{
+		{Name: "github.com/x/x", Version: "^1.0.0", Tag: "1.0.0", SHA: "x-1.0.0", Source: "https://github.com/x/x.git", InstallPath: "ahkpm-modules/github.com/x/x", Dependencies: cDeps},
+		{Name: "github.com/c/c", Version: "^1.0.0", Tag: "1.0.0", SHA: "c-1.0.0", Source: "https://github.com/c/c.git", InstallPath: "ahkpm-modules/github.com/x/x/ahkpm-modules/github.com/c/c", Dependencies: emptySet},
+		{Name: "github.com/y/y", Version: "^1.0.0", Tag: "1.0.0", SHA: "y-1.0.0", Source: "https://github.com/y/y.git", InstallPath: "ahkpm-modules/github.com/y/y", Dependencies: cDeps},
+		{Name: "github.com/c/c", Version: "^1.0.0", Tag: "1.0.0", SHA: "c-1.0.0", Source: "https://github.com/c/c.git", InstallPath: "ahkpm-modules/github.com/y/y/ahkpm-modules/github.com/c/c", Dependencies: emptySet},
+	})
+	deduped, _ := DedupeResolvedDependencies(tree)
+	laidOut, err := deduped.WithInstallLayout(NestedInstallLayout)
+	assert.NoError(t, err)
+	locked := laidOut.Flatten()
+
+	// A later install resolves y again, which builds its copy of c afresh
+	depY := NewDependency("github.com/y/y", NewVersion(SemVerRange, "^1.0.0"))
+	changedDeps := NewDependencySet().AddDependency(depY)
+	allDeps := NewDependencySet().
+		AddDependency(NewDependency("github.com/x/x", NewVersion(SemVerRange, "^1.0.0"))).
+		AddDependency(depY)
+
+	mockPR.On("GetVersions", "github.com/y/y").Return([]string{"1.0.0"}, nil)
+	mockPR.On("GetVersions", "github.com/c/c").Return([]string{"1.0.0"}, nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/y/y", NewVersion(SemVerExact, "1.0.0"))).Return("y-1.0.0", nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/c/c", NewVersion(SemVerExact, "1.0.0"))).Return("c-1.0.0", nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == "github.com/y/y"
+	})).Return(&cDeps, nil)
+	mockPR.On("GetPackageDependencies", mock.MatchedBy(func(rd ResolvedDependency) bool {
+		return rd.Name == "github.com/c/c"
+	})).Return(&emptySet, nil)
+
+	updated, err := ResolveUpdates(resolver, locked, changedDeps, allDeps)
+	assert.NoError(t, err)
+	reinstalled, err := updated.WithInstallLayout(NestedInstallLayout)
+	assert.NoError(t, err)
+
+	for _, dep := range reinstalled.Flatten() {
+		if dep.Name == "github.com/c/c" {
+			assert.True(t, dep.Deduped)
+			assert.Equal(t, "ahkpm-modules/github.com/c/c", dep.InstallPath)
+		}
+	}
+	assert.Equal(t, locked, reinstalled.Flatten())
+}
@@ -3,18 +3,23 @@ package core
 import (
 	"ahkpm/src/utils"
 	"encoding/json"
+	"fmt"
 	"os"
+	"strconv"
 )
 
 type LockManifest struct {
 	LockfileVersion string               `json:"lockfileVersion"`
 	Dependencies    DependencySet        `json:"dependencies"`
 	Resolved        []ResolvedDependency `json:"resolved"`
+	// MigratedFrom is the lockfile version which was read from disk, if it
+	// was older than the current version. It is never saved.
+	MigratedFrom string `json:"-"`
 }
 
 func NewLockManifest() LockManifest {
 	return LockManifest{
-		LockfileVersion: "1",
+		LockfileVersion: strconv.Itoa(CurrentLockfileVersion),
 		Dependencies:    NewDependencySet(),
 		Resolved:        make([]ResolvedDependency, 0),
 	}
@@ -36,7 +41,7 @@ func (lm LockManifest) SaveToCwd() LockManifest {
 	if err != nil {
 		utils.Exit("Error marshalling ahkpm.lock to bytes")
 	}
-	err = os.WriteFile("ahkpm.lock", jsonBytes, 0644)
+	err = utils.WriteFileAtomic("ahkpm.lock", jsonBytes, 0644)
 	if err != nil {
 		utils.Exit("Error writing ahkpm.lock")
 	}
@@ -51,7 +56,13 @@ func LockManifestFromCwd() (*LockManifest, error) {
 	return lm, nil
 }
 
+// LockManifestFromFile reads a lockfile, migrating it to the current lockfile
+// version if needed. The migrated lockfile is only written back to disk when
+// it is next saved.
 func LockManifestFromFile(path string) (*LockManifest, error) {
-	lm := NewLockManifest()
-	return utils.StructFromFile(path, &lm)
+	jsonBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %w", path, err)
+	}
+	return lockManifestFromJson(jsonBytes)
 }
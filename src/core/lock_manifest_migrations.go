@@ -0,0 +1,129 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// CurrentLockfileVersion is the lockfile format written by this version of
+// ahkpm
+const CurrentLockfileVersion = 2
+
+// lockfileMigration upgrades the raw JSON of a lockfile by one version
+type lockfileMigration func(raw map[string]any) (map[string]any, error)
+
+// lockfileMigrations maps each lockfile version to the migration which
+// upgrades it to the next version. To change the lockfile format, increment
+// CurrentLockfileVersion and add a migration from the previous version.
+var lockfileMigrations = map[int]lockfileMigration{
+	1: migrateLockfileV1ToV2,
+}
+
+// migrateLockfile upgrades the raw JSON of a lockfile to the current version,
+// returning the version it started at
+func migrateLockfile(raw map[string]any) (map[string]any, int, error) {
+	originalVersion, err := getLockfileVersion(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if originalVersion > CurrentLockfileVersion {
+		return nil, originalVersion, fmt.Errorf(
+			"ahkpm.lock uses lockfile version %d, but this version of ahkpm only supports up to version %d. Please upgrade ahkpm.",
+			originalVersion,
+			CurrentLockfileVersion,
+		)
+	}
+
+	for version := originalVersion; version < CurrentLockfileVersion; version++ {
+		migrate, ok := lockfileMigrations[version]
+		if !ok {
+			return nil, originalVersion, fmt.Errorf("Unable to migrate ahkpm.lock from lockfile version %d", version)
+		}
+		raw, err = migrate(raw)
+		if err != nil {
+			return nil, originalVersion, err
+		}
+		raw["lockfileVersion"] = strconv.Itoa(version + 1)
+	}
+
+	return raw, originalVersion, nil
+}
+
+// getLockfileVersion reads the lockfile version, which may be either a string
+// or a number. Lockfiles without a version are treated as version 1.
+func getLockfileVersion(raw map[string]any) (int, error) {
+	switch value := raw["lockfileVersion"].(type) {
+	case nil:
+		return 1, nil
+	case float64:
+		if value == float64(int(value)) && value >= 1 {
+			return int(value), nil
+		}
+	case string:
+		version, err := strconv.Atoi(value)
+		if err == nil && version >= 1 {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid lockfile version in ahkpm.lock: %v", raw["lockfileVersion"])
+}
+
+// migrateLockfileV1ToV2 adds the source of each resolved dependency. The tag,
+// integrity and resolution time cannot be recovered, so they are left empty
+// until the dependency is next resolved or installed.
+func migrateLockfileV1ToV2(raw map[string]any) (map[string]any, error) {
+	resolved, ok := raw["resolved"].([]any)
+	if !ok {
+		if raw["resolved"] == nil {
+			return raw, nil
+		}
+		return nil, errors.New("Invalid list of resolved dependencies in ahkpm.lock")
+	}
+
+	for _, item := range resolved {
+		dep, ok := item.(map[string]any)
+		if !ok {
+			return nil, errors.New("Invalid resolved dependency in ahkpm.lock")
+		}
+		name, ok := dep["name"].(string)
+		if ok && dep["source"] == nil {
+			dep["source"] = getGitUrl(name)
+		}
+	}
+
+	return raw, nil
+}
+
+// lockManifestFromJson parses a lockfile of any supported version, migrating
+// it to the current version in memory
+func lockManifestFromJson(jsonBytes []byte) (*LockManifest, error) {
+	var raw map[string]any
+	err := json.Unmarshal(jsonBytes, &raw)
+	if err != nil {
+		return nil, errors.New("Error parsing ahkpm.lock: " + err.Error())
+	}
+
+	raw, originalVersion, err := migrateLockfile(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	migratedBytes, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	lm := NewLockManifest()
+	err = json.Unmarshal(migratedBytes, &lm)
+	if err != nil {
+		return nil, errors.New("Error parsing ahkpm.lock: " + err.Error())
+	}
+	if originalVersion != CurrentLockfileVersion {
+		lm.MigratedFrom = strconv.Itoa(originalVersion)
+	}
+
+	return &lm, nil
+}
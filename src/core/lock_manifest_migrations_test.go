@@ -0,0 +1,75 @@
+package core_test
+
+import (
+	. "ahkpm/src/core"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLockfile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "ahkpm.lock")
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	return path
+}
+
+func TestLockManifestFromFileMigratesVersion1(t *testing.T) {
+	path := writeLockfile(t, `{
+		"lockfileVersion": 1,
+		"dependencies": { "github.com/a/a": "^1.0.0" },
+		"resolved": [
+			{
+				"name": "github.com/a/a",
+				"version": "^1.0.0",
+				"sha": "1234567890",
+				"installPath": "ahkpm-modules/github.com/a/a",
+				"dependencies": {}
+			}
+		]
+	}`)
+
+	lm, err := LockManifestFromFile(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2", lm.LockfileVersion)
+	assert.Equal(t, "1", lm.MigratedFrom)
+	assert.Equal(t, []ResolvedDependency{
+		{
+			Name:         "github.com/a/a",
+			Version:      "^1.0.0",
+			SHA:          "1234567890",
+			Source:       "https://github.com/a/a.git",
+			InstallPath:  "ahkpm-modules/github.com/a/a",
+			Dependencies: NewDependencySet(),
+		},
+	}, lm.Resolved)
+	assert.True(t, lm.Dependencies.Contains("github.com/a/a"))
+}
+
+func TestLockManifestFromFileWithCurrentVersion(t *testing.T) {
+	path := writeLockfile(t, `{"lockfileVersion": "2", "dependencies": {}, "resolved": []}`)
+
+	lm, err := LockManifestFromFile(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2", lm.LockfileVersion)
+	assert.Equal(t, "", lm.MigratedFrom)
+}
+
+func TestLockManifestFromFileWithFutureVersion(t *testing.T) {
+	path := writeLockfile(t, `{"lockfileVersion": "99", "dependencies": {}, "resolved": []}`)
+
+	_, err := LockManifestFromFile(path)
+
+	assert.ErrorContains(t, err, "Please upgrade ahkpm")
+}
+
+func TestLockManifestFromFileWithInvalidVersion(t *testing.T) {
+	path := writeLockfile(t, `{"lockfileVersion": "beta", "dependencies": {}, "resolved": []}`)
+
+	_, err := LockManifestFromFile(path)
+
+	assert.ErrorContains(t, err, "Invalid lockfile version")
+}
@@ -3,7 +3,6 @@ package core
 import (
 	"ahkpm/src/utils"
 	"encoding/json"
-	"os"
 )
 
 // Manifest contains the data from ahkpm.json
@@ -18,6 +17,10 @@ type Manifest struct {
 	Author       Person            `json:"author"`
 	Scripts      map[string]string `json:"scripts"`
 	Dependencies DependencySet     `json:"dependencies"`
+	// InstallLayout is either "nested" (the default) or "flat"
+	InstallLayout string `json:"installLayout,omitempty"`
+	// InstallMode is either "copy" (the default), "symlink" or "hardlink"
+	InstallMode string `json:"installMode,omitempty"`
 }
 
 type Person struct {
@@ -60,7 +63,7 @@ func (m Manifest) SaveToCwd() Manifest {
 	if err != nil {
 		utils.Exit("Error marshalling ahkpm.json to bytes")
 	}
-	err = os.WriteFile("ahkpm.json", jsonBytes, 0644)
+	err = utils.WriteFileAtomic("ahkpm.json", jsonBytes, 0644)
 	if err != nil {
 		utils.Exit("Error writing ahkpm.json")
 	}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"github.com/Masterminds/semver/v3"
+)
+
+// UnknownVersion is shown in place of a version which could not be found
+const UnknownVersion = "n/a"
+
+// OutdatedDependency compares the locked version of a dependency with the
+// newest versions available for it
+type OutdatedDependency struct {
+	Name string `json:"name"`
+	// Requested is the version specifier in ahkpm.json, or in the manifest of
+	// the package which depends on it
+	Requested string `json:"requested"`
+	// Current is the locked version. For branches and commits without a
+	// matching tag it is the short SHA of the locked commit.
+	Current string `json:"current"`
+	// Wanted is the newest version allowed by Requested
+	Wanted string `json:"wanted"`
+	// Latest is the newest version available, regardless of Requested
+	Latest string `json:"latest"`
+	// Error explains why the dependency could not be fully checked
+	Error string `json:"error,omitempty"`
+}
+
+// GetOutdatedDependencies checks every resolved dependency in the lockfile and
+// returns those for which a newer version is wanted or available. Dependencies
+// which could not be checked are returned as well, with the reason in Error,
+// so that one of them doesn't hide the others.
+func GetOutdatedDependencies(pr PackagesRepository, lm LockManifest) []OutdatedDependency {
+	outdated := make([]OutdatedDependency, 0)
+	seen := make(map[string]bool)
+
+	for _, resolved := range lm.Resolved {
+		key := resolved.Name + "@" + resolved.Version + "@" + resolved.SHA
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		od, isOutdated := getOutdatedDependency(pr, resolved)
+		if isOutdated || od.Error != "" {
+			outdated = append(outdated, od)
+		}
+	}
+
+	return outdated
+}
+
+// GetUpdatableDependencies returns the outdated dependencies which are listed
+// in ahkpm.json, since only those can be updated directly
+func GetUpdatableDependencies(pr PackagesRepository, lm LockManifest, manifestDeps DependencySet) []OutdatedDependency {
+	depsByName := manifestDeps.AsMap()
+	updatable := make([]OutdatedDependency, 0)
+	for _, od := range GetOutdatedDependencies(pr, lm) {
+		dep, ok := depsByName[od.Name]
+		if ok && dep.Version().String() == od.Requested {
+			updatable = append(updatable, od)
+		}
+	}
+	return updatable
+}
+
+// getOutdatedDependency compares the locked version of the dependency with
+// the newest versions, and returns true if it is outdated. Versions which
+// can't be looked up are shown as UnknownVersion, with the reason in Error.
+func getOutdatedDependency(pr PackagesRepository, resolved ResolvedDependency) (OutdatedDependency, bool) {
+	od := OutdatedDependency{
+		Name:      resolved.Name,
+		Requested: resolved.Version,
+		Current:   UnknownVersion,
+		Wanted:    UnknownVersion,
+		Latest:    UnknownVersion,
+	}
+
+	version, err := VersionFromSpecifier(resolved.Version)
+	if err != nil {
+		od.Error = err.Error()
+		return od, false
+	}
+
+	// Packages without semantic version tags or a main branch have no latest
+	// version, which is not an error
+	latest, err := pr.GetLatestVersion(resolved.Name)
+	if err == nil {
+		od.Latest = latest.String()
+	} else if err.Error() != "No matching versions found" {
+		od.Error = err.Error()
+	}
+
+	switch version.Kind() {
+	case SemVerRange, SemVerExact:
+		current, err := getCurrentVersion(pr, resolved)
+		if err != nil {
+			od.Error = err.Error()
+			return od, false
+		}
+		od.Current = current
+
+		tags, err := pr.GetVersions(resolved.Name)
+		if err != nil {
+			od.Error = err.Error()
+			return od, false
+		}
+		wanted, err := GetLatestVersionMatchingRangeFromArray(tags, version.Value())
+		if err != nil {
+			od.Error = "No version matches " + version.Value()
+		} else {
+			od.Wanted = wanted
+		}
+
+		return od, isUpdate(od.Wanted, od.Current) || isUpdate(od.Latest, od.Current)
+
+	case Branch:
+		// A branch is outdated when its head has moved past the locked commit
+		od.Current = ShortSHA(resolved.SHA)
+		headSHA, err := pr.GetResolvedDependencySHA(NewDependency(resolved.Name, version))
+		if err != nil {
+			od.Error = err.Error()
+			return od, false
+		}
+		od.Wanted = ShortSHA(headSHA)
+		if latest != nil && latest.Equals(version) {
+			od.Latest = od.Wanted
+		}
+
+		return od, headSHA != resolved.SHA
+
+	default:
+		// Tags and commits always refer to the same commit, so they are
+		// only reported if a newer semantic version is available
+		current, err := getCurrentVersion(pr, resolved)
+		if err != nil {
+			od.Error = err.Error()
+			return od, false
+		}
+		od.Current = current
+		od.Wanted = current
+
+		return od, isNewerVersion(od.Latest, od.Current)
+	}
+}
+
+// getCurrentVersion returns the highest semantic version tag of the locked
+// commit, or its short SHA if it has none
+func getCurrentVersion(pr PackagesRepository, resolved ResolvedDependency) (string, error) {
+	current, err := pr.GetVersionForSHA(resolved.Name, resolved.SHA)
+	if err != nil {
+		return "", err
+	}
+	if current == "" {
+		current = ShortSHA(resolved.SHA)
+	}
+	return current, nil
+}
+
+// GetUpdateChoices returns the ways in which an outdated dependency can be
+// updated. "wanted" stays within the requested range, while "latest" moves the
+// range in ahkpm.json to the latest version.
+func GetUpdateChoices(od OutdatedDependency) []string {
+	choices := []string{"none"}
+	if od.Wanted != od.Current && od.Wanted != UnknownVersion {
+		choices = append(choices, "wanted")
+	}
+	if isNewerVersion(od.Latest, od.Wanted) {
+		choices = append(choices, "latest")
+	}
+	return choices
+}
+
+// isUpdate returns true if target is a semantic version which the locked
+// version should be updated to. A current version which isn't a semantic
+// version, such as the SHA of a commit which is no longer tagged, can't be
+// compared and so is always updated.
+func isUpdate(target string, current string) bool {
+	_, err := semver.StrictNewVersion(target)
+	if err != nil {
+		return false
+	}
+	_, err = semver.StrictNewVersion(current)
+	if err != nil {
+		return true
+	}
+	return isNewerVersion(target, current)
+}
+
+// isNewerVersion returns true if both strings are semantic versions and the
+// first is greater than the second
+func isNewerVersion(a string, b string) bool {
+	versionA, err := semver.StrictNewVersion(a)
+	if err != nil {
+		return false
+	}
+	versionB, err := semver.StrictNewVersion(b)
+	if err != nil {
+		return false
+	}
+	return versionA.GreaterThan(versionB)
+}
+
+// ShortSHA abbreviates a commit SHA for display
+func ShortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,122 @@
+package core_test
+
+import (
+	. "ahkpm/src/core"
+	"ahkpm/src/mocks"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOutdatedDependencies(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	lm := NewLockManifest()
+	lm.Resolved = []ResolvedDependency{
+		{Name: "github.com/a/a", Version: "^1.0.0", SHA: "aaaaaaa1111"},
+		{Name: "github.com/b/b", Version: "^2.0.0", SHA: "bbbbbbb2222"},
+		{Name: "github.com/c/c", Version: "branch:main", SHA: "ccccccc3333"},
+		{Name: "github.com/d/d", Version: "branch:main", SHA: "ddddddd4444"},
+	}
+
+	// a is behind both the wanted and the latest version
+	mockPR.On("GetLatestVersion", "github.com/a/a").Return(NewVersion(SemVerExact, "2.0.0"), nil)
+	mockPR.On("GetVersionForSHA", "github.com/a/a", "aaaaaaa1111").Return("1.0.0", nil)
+	mockPR.On("GetVersions", "github.com/a/a").Return([]string{"1.0.0", "1.1.0", "2.0.0"}, nil)
+
+	// b is up to date
+	mockPR.On("GetLatestVersion", "github.com/b/b").Return(NewVersion(SemVerExact, "2.1.0"), nil)
+	mockPR.On("GetVersionForSHA", "github.com/b/b", "bbbbbbb2222").Return("2.1.0", nil)
+	mockPR.On("GetVersions", "github.com/b/b").Return([]string{"2.0.0", "2.1.0"}, nil)
+
+	// the head of c's branch has moved
+	mockPR.On("GetLatestVersion", "github.com/c/c").Return(NewVersion(Branch, "main"), nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/c/c", NewVersion(Branch, "main"))).Return("eeeeeee5555", nil)
+
+	// the head of d's branch has not moved
+	mockPR.On("GetLatestVersion", "github.com/d/d").Return(NewVersion(Branch, "main"), nil)
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/d/d", NewVersion(Branch, "main"))).Return("ddddddd4444", nil)
+
+	outdated := GetOutdatedDependencies(mockPR, lm)
+
+	assert.Equal(t, []OutdatedDependency{
+		{Name: "github.com/a/a", Requested: "^1.0.0", Current: "1.0.0", Wanted: "1.1.0", Latest: "2.0.0"},
+		{Name: "github.com/c/c", Requested: "branch:main", Current: "ccccccc", Wanted: "eeeeeee", Latest: "eeeeeee"},
+	}, outdated)
+}
+
+func TestGetOutdatedDependenciesWithUntaggedCommit(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	lm := NewLockManifest()
+	lm.Resolved = []ResolvedDependency{
+		{Name: "github.com/a/a", Version: "^1.0.0", SHA: "aaaaaaa1111"},
+	}
+
+	// The locked commit is no longer tagged, so only its SHA is known
+	mockPR.On("GetLatestVersion", "github.com/a/a").Return(NewVersion(SemVerExact, "1.1.0"), nil)
+	mockPR.On("GetVersionForSHA", "github.com/a/a", "aaaaaaa1111").Return("", nil)
+	mockPR.On("GetVersions", "github.com/a/a").Return([]string{"1.0.0", "1.1.0"}, nil)
+
+	outdated := GetOutdatedDependencies(mockPR, lm)
+
+	assert.Equal(t, []OutdatedDependency{
+		{Name: "github.com/a/a", Requested: "^1.0.0", Current: "aaaaaaa", Wanted: "1.1.0", Latest: "1.1.0"},
+	}, outdated)
+}
+
+func TestGetOutdatedDependenciesReportsErrorsPerDependency(t *testing.T) {
+	mockPR := &mocks.MockPackagesRepository{}
+	lm := NewLockManifest()
+	lm.Resolved = []ResolvedDependency{
+		{Name: "github.com/a/a", Version: "branch:develop", SHA: "aaaaaaa1111"},
+		{Name: "github.com/b/b", Version: "^3.0.0", SHA: "bbbbbbb2222"},
+		{Name: "github.com/c/c", Version: "^1.0.0", SHA: "ccccccc3333"},
+		{Name: "github.com/d/d", Version: "^1.0.0", SHA: "ddddddd4444"},
+	}
+
+	// a only has a develop branch, so there is no latest version
+	mockPR.On("GetLatestVersion", "github.com/a/a").Return(nil, errors.New("No matching versions found"))
+	mockPR.On("GetResolvedDependencySHA", NewDependency("github.com/a/a", NewVersion(Branch, "develop"))).Return("eeeeeee5555", nil)
+
+	// No tag of b matches its range any more
+	mockPR.On("GetLatestVersion", "github.com/b/b").Return(NewVersion(SemVerExact, "2.0.0"), nil)
+	mockPR.On("GetVersionForSHA", "github.com/b/b", "bbbbbbb2222").Return("", nil)
+	mockPR.On("GetVersions", "github.com/b/b").Return([]string{"1.0.0", "2.0.0"}, nil)
+
+	// c can't be fetched
+	mockPR.On("GetLatestVersion", "github.com/c/c").Return(nil, errors.New("Error fetching package github.com/c/c"))
+	mockPR.On("GetVersionForSHA", "github.com/c/c", "ccccccc3333").Return("", errors.New("Error fetching package github.com/c/c"))
+
+	// d is still checked, and is outdated
+	mockPR.On("GetLatestVersion", "github.com/d/d").Return(NewVersion(SemVerExact, "1.1.0"), nil)
+	mockPR.On("GetVersionForSHA", "github.com/d/d", "ddddddd4444").Return("1.0.0", nil)
+	mockPR.On("GetVersions", "github.com/d/d").Return([]string{"1.0.0", "1.1.0"}, nil)
+
+	outdated := GetOutdatedDependencies(mockPR, lm)
+
+	assert.Equal(t, []OutdatedDependency{
+		{Name: "github.com/a/a", Requested: "branch:develop", Current: "aaaaaaa", Wanted: "eeeeeee", Latest: "n/a"},
+		{Name: "github.com/b/b", Requested: "^3.0.0", Current: "bbbbbbb", Wanted: "n/a", Latest: "2.0.0", Error: "No version matches ^3.0.0"},
+		{Name: "github.com/c/c", Requested: "^1.0.0", Current: "n/a", Wanted: "n/a", Latest: "n/a", Error: "Error fetching package github.com/c/c"},
+		{Name: "github.com/d/d", Requested: "^1.0.0", Current: "1.0.0", Wanted: "1.1.0", Latest: "1.1.0"},
+	}, outdated)
+}
+
+func TestGetUpdateChoices(t *testing.T) {
+	type Case struct {
+		outdated OutdatedDependency
+		expected []string
+	}
+
+	cases := []Case{
+		{OutdatedDependency{Current: "1.0.0", Wanted: "1.1.0", Latest: "2.0.0"}, []string{"none", "wanted", "latest"}},
+		{OutdatedDependency{Current: "1.0.0", Wanted: "1.1.0", Latest: "1.1.0"}, []string{"none", "wanted"}},
+		{OutdatedDependency{Current: "1.1.0", Wanted: "1.1.0", Latest: "2.0.0"}, []string{"none", "latest"}},
+		{OutdatedDependency{Current: "ccccccc", Wanted: "eeeeeee", Latest: "eeeeeee"}, []string{"none", "wanted"}},
+		{OutdatedDependency{Current: "bbbbbbb", Wanted: "n/a", Latest: "2.0.0"}, []string{"none"}},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, GetUpdateChoices(c.outdated))
+	}
+}
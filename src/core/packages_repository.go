@@ -6,29 +6,116 @@ import (
 	"ahkpm/src/utils"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/otiai10/copy"
+	"golang.org/x/exp/slices"
 )
 
 type PackagesRepository interface {
 	CopyPackage(dep ResolvedDependency, path string) error
+	// ReextractPackage replaces the cached files of the package with a fresh
+	// copy from its repository
+	ReextractPackage(dep ResolvedDependency) error
 	GetPackageDependencies(dep ResolvedDependency) (*DependencySet, error)
 	GetResolvedDependencySHA(dep Dependency) (string, error)
 	GetLatestVersion(depName string) (Version, error)
+	GetVersions(depName string) ([]string, error)
+	GetVersionForSHA(depName string, sha string) (string, error)
+	GetPackageIntegrity(dep ResolvedDependency) (string, error)
 	ClearCache() error
+	GetCacheDir() string
+	ListCache() ([]CachedPackage, error)
+	AddToCache(dep Dependency) (string, error)
+	RemoveFromCache(depName string) error
+	VerifyCache() ([]CacheIssue, error)
+	CollectCacheGarbage(limits CacheLimits, protected []ResolvedDependency) ([]CacheEviction, error)
+	// WithInstallMode sets how CopyPackage installs packages. It is one of
+	// CopyInstallMode, SymlinkInstallMode or HardlinkInstallMode.
+	WithInstallMode(installMode string) PackagesRepository
 	// For testing
 	WithRemoveAll(removeAll func(path string) error) PackagesRepository
 }
 
+const (
+	// CopyInstallMode installs a full copy of each package
+	CopyInstallMode = "copy"
+	// SymlinkInstallMode installs each package as symbolic links to its
+	// snapshot in the cache
+	SymlinkInstallMode = "symlink"
+	// HardlinkInstallMode installs each package as hard links to the files
+	// of its snapshot in the cache
+	HardlinkInstallMode = "hardlink"
+)
+
 type packagesRepository struct {
-	removeAll func(path string) error
+	removeAll   func(path string) error
+	installMode string
+	// warnOnce ensures that falling back to copying is only reported once
+	warnOnce sync.Once
+}
+
+// packageLocks serializes access to each package's repository in the cache
+// within this process. It is shared by all instances so that concurrent
+// resolution cannot fetch the same package twice. Other ahkpm processes are
+// kept out by a lock file while a repository is being cloned or fetched.
+var packageLocks = utils.NewKeyedMutex()
+
+// installCopyOptions makes installed copies of packages writable, unlike the
+// read-only snapshots in the cache which they are copied from
+var installCopyOptions = copy.Options{PermissionControl: copy.AddPermission(0200)}
+
+// offline prevents every packagesRepository from accessing the network. It is
+// set once at startup.
+var offline = false
+
+// SetOffline sets whether packages may only be resolved from the cache,
+// without cloning or fetching anything
+func SetOffline(value bool) {
+	offline = value
+}
+
+// isOffline returns true if packages may only be resolved from the cache,
+// because of SetOffline or the offline setting in config.json. The config is
+// only read once a package would be downloaded.
+func isOffline() (bool, error) {
+	if offline {
+		return true, nil
+	}
+	config, err := GetConfig()
+	if err != nil {
+		return false, err
+	}
+	return config.Offline, nil
+}
+
+// NotInCacheError is returned in offline mode when a package, or a version
+// of it, would have to be downloaded
+type NotInCacheError struct {
+	Name string
+	// Version is the branch, tag or commit which was not found, if the
+	// package itself is in the cache
+	Version string
+}
+
+func (e NotInCacheError) Error() string {
+	if e.Version == "" {
+		return e.Name + " is not in the cache, and cannot be downloaded in offline mode"
+	}
+	return "Version " + e.Version + " of " + e.Name + " is not in the cache, and cannot be downloaded in offline mode"
 }
 
 func init() {
@@ -38,7 +125,7 @@ func init() {
 
 func NewPackagesRepository() PackagesRepository {
 	return &packagesRepository{
-		removeAll: os.RemoveAll,
+		removeAll: utils.ForceRemoveAll,
 	}
 }
 
@@ -47,29 +134,79 @@ func (pr *packagesRepository) WithRemoveAll(removeAll func(path string) error) P
 	return pr
 }
 
+func (pr *packagesRepository) WithInstallMode(installMode string) PackagesRepository {
+	pr.installMode = installMode
+	return pr
+}
+
+// CopyPackage installs the package at path, either by copying its files or
+// by linking to them, depending on the install mode. If linking fails, for
+// example because the file system doesn't support it, the files are copied.
 func (pr *packagesRepository) CopyPackage(dep ResolvedDependency, path string) error {
-	err := pr.ensurePackageIsReady(dep.Name, dep.SHA)
+	defer packageLocks.Lock(dep.Name)()
+
+	snapshotDir, err := pr.ensureSnapshot(dep.Name, dep.SHA)
 	if err != nil {
 		return err
 	}
-	err = copy.Copy(pr.getPackageCacheDir(dep.Name), path, copy.Options{
-		// Skip the .git directory since it isn't needed at the destination
-		Skip: func(srcInfo fs.FileInfo, src string, dest string) (bool, error) {
-			return strings.HasSuffix(src, ".git"), nil
-		},
-	})
+
+	if pr.installMode == SymlinkInstallMode || pr.installMode == HardlinkInstallMode {
+		err = linkSnapshot(snapshotDir, path, pr.installMode)
+		if err == nil {
+			return nil
+		}
+		pr.warnOnce.Do(func() {
+			fmt.Println("Unable to " + pr.installMode + " packages from the cache. Copying them instead.")
+		})
+		err = os.RemoveAll(path)
+		if err != nil {
+			return errors.New("Error copying package to target module directory")
+		}
+	}
+
+	err = copy.Copy(snapshotDir, path, installCopyOptions)
 	if err != nil {
 		return errors.New("Error copying package to target module directory")
 	}
 	return nil
 }
 
+// ReextractPackage replaces the cached files of the package at the resolved
+// SHA with a fresh copy extracted from its repository, in case they were
+// modified through a link
+func (pr *packagesRepository) ReextractPackage(dep ResolvedDependency) error {
+	// Hold the lock throughout, so that nothing else sees the snapshot while
+	// it is missing or only partly extracted
+	defer packageLocks.Lock(dep.Name)()
+
+	err := pr.removeSnapshot(dep.Name, dep.SHA)
+	if err != nil {
+		return err
+	}
+	_, err = pr.ensureSnapshot(dep.Name, dep.SHA)
+	return err
+}
+
+// GetPackageIntegrity returns the hash of the package's files at the resolved
+// SHA, as they would be installed by CopyPackage
+func (pr *packagesRepository) GetPackageIntegrity(dep ResolvedDependency) (string, error) {
+	defer packageLocks.Lock(dep.Name)()
+
+	snapshotDir, err := pr.ensureSnapshot(dep.Name, dep.SHA)
+	if err != nil {
+		return "", err
+	}
+	return utils.HashDirectory(snapshotDir, ".git", "ahkpm-modules")
+}
+
 func (pr *packagesRepository) GetPackageDependencies(dep ResolvedDependency) (*DependencySet, error) {
-	err := pr.ensurePackageIsReady(dep.Name, dep.SHA)
+	defer packageLocks.Lock(dep.Name)()
+
+	snapshotDir, err := pr.ensureSnapshot(dep.Name, dep.SHA)
 	if err != nil {
 		return nil, err
 	}
-	manifestPath := pr.getPackageCacheDir(dep.Name) + `/ahkpm.json`
+	manifestPath := snapshotDir + `/ahkpm.json`
 	manifest, err := ManifestFromFile(manifestPath)
 
 	deps := NewDependencySet()
@@ -86,13 +223,15 @@ func (pr *packagesRepository) GetPackageDependencies(dep ResolvedDependency) (*D
 // it will fall back to "branch:main", and then to "branch:master". If none of these are
 // found, it will return an error.
 func (pr *packagesRepository) GetLatestVersion(depName string) (Version, error) {
+	defer packageLocks.Lock(depName)()
+
 	dep, err := pr.getVersionMatchingSemVerRange(NewDependency(depName, NewVersion(SemVerRange, "*")))
 	if err != nil {
 		if err.Error() == "No matching versions found" {
-			if pr.HasBranch(depName, "main") {
+			if pr.hasBranch(depName, "main") {
 				return NewVersion(Branch, "main"), nil
 			}
-			if pr.HasBranch(depName, "master") {
+			if pr.hasBranch(depName, "master") {
 				return NewVersion(Branch, "master"), nil
 			}
 		}
@@ -103,7 +242,13 @@ func (pr *packagesRepository) GetLatestVersion(depName string) (Version, error)
 
 // HasBranch returns true if the package has a branch with the given name
 func (pr *packagesRepository) HasBranch(depName string, branchName string) bool {
-	repo, _, err := pr.ensurePackageIsUpToDate(depName)
+	defer packageLocks.Lock(depName)()
+
+	return pr.hasBranch(depName, branchName)
+}
+
+func (pr *packagesRepository) hasBranch(depName string, branchName string) bool {
+	repo, err := pr.ensurePackageIsUpToDate(depName)
 	if err != nil {
 		return false
 	}
@@ -127,6 +272,13 @@ func (pr *packagesRepository) HasBranch(depName string, branchName string) bool
 }
 
 func (pr *packagesRepository) GetResolvedDependencySHA(dep Dependency) (string, error) {
+	defer packageLocks.Lock(dep.Name())()
+
+	if sha, ok := session.getSHA(dep); ok {
+		return sha, nil
+	}
+	requestedDep := dep
+
 	if dep.Version().Kind() == SemVerRange {
 		exactDep, err := pr.getVersionMatchingSemVerRange(dep)
 		if err != nil {
@@ -136,27 +288,55 @@ func (pr *packagesRepository) GetResolvedDependencySHA(dep Dependency) (string,
 		dep = exactDep
 	}
 
-	err := pr.ensurePackageIsReady(dep.Name(), dep.Version().Value())
+	repo, err := pr.ensurePackageIsUpToDate(dep.Name())
 	if err != nil {
 		return "", err
 	}
-	repo, err := git.PlainOpen(pr.getPackageCacheDir(dep.Name()))
-	if err != nil {
-		return "", errors.New("Error opening package repository " + dep.Name())
-	}
-	ref, err := repo.Head()
+	hash, err := resolveRevision(repo, dep.Name(), dep.Version().Value())
 	if err != nil {
-		return "", errors.New("Error getting package repository HEAD" + dep.Name())
+		if offlineMode, _ := isOffline(); offlineMode {
+			return "", NotInCacheError{Name: dep.Name(), Version: dep.Version().Value()}
+		}
+		return "", err
 	}
-	return ref.Hash().String(), nil
+
+	sha := hash.String()
+	session.setSHA(requestedDep, sha)
+	return sha, nil
 }
 
 func (pr *packagesRepository) getVersionMatchingSemVerRange(dep Dependency) (Dependency, error) {
-	repo, _, err := pr.ensurePackageIsUpToDate(dep.Name())
+	tags, err := pr.getVersions(dep.Name())
 	if err != nil {
 		return dep, err
 	}
 
+	latestMatchingVersion, err := GetLatestVersionMatchingRangeFromArray(tags, dep.Version().Value())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDependency(dep.Name(), NewVersion(SemVerExact, latestMatchingVersion)), nil
+}
+
+// GetVersions returns the names of all tags in the package's repository. Tags
+// which are not valid semantic versions are included as well.
+func (pr *packagesRepository) GetVersions(depName string) ([]string, error) {
+	defer packageLocks.Lock(depName)()
+
+	return pr.getVersions(depName)
+}
+
+func (pr *packagesRepository) getVersions(depName string) ([]string, error) {
+	if tags, ok := session.getTags(depName); ok {
+		return tags, nil
+	}
+
+	repo, err := pr.ensurePackageIsUpToDate(depName)
+	if err != nil {
+		return nil, err
+	}
+
 	tagIter, err := repo.Tags()
 	if err != nil {
 		return nil, err
@@ -171,15 +351,47 @@ func (pr *packagesRepository) getVersionMatchingSemVerRange(dep Dependency) (Dep
 		return nil, err
 	}
 
-	latestMatchingVersion, err := GetLatestVersionMatchingRangeFromArray(tags, dep.Version().Value())
+	session.setTags(depName, tags)
+	return tags, nil
+}
+
+// GetVersionForSHA returns the highest semantic version tag which points to
+// the given commit. If there is no such tag, it returns an empty string.
+func (pr *packagesRepository) GetVersionForSHA(depName string, sha string) (string, error) {
+	defer packageLocks.Lock(depName)()
+
+	tags, err := pr.getVersions(depName)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return NewDependency(dep.Name(), NewVersion(SemVerExact, latestMatchingVersion)), nil
+	repo, err := pr.ensurePackageIsUpToDate(depName)
+	if err != nil {
+		return "", err
+	}
+
+	matchingVersions := make([]*semver.Version, 0)
+	for _, tag := range tags {
+		version, err := semver.StrictNewVersion(tag)
+		if err != nil {
+			continue
+		}
+		hash, err := repo.ResolveRevision(plumbing.Revision(tag))
+		if err == nil && hash.String() == sha {
+			matchingVersions = append(matchingVersions, version)
+		}
+	}
+
+	if len(matchingVersions) == 0 {
+		return "", nil
+	}
+
+	sort.Sort(semver.Collection(matchingVersions))
+	return matchingVersions[len(matchingVersions)-1].Original(), nil
 }
 
 func (pr *packagesRepository) ClearCache() error {
+	session.reset()
 	return pr.removeAll(pr.getCacheDir())
 }
 
@@ -187,123 +399,532 @@ func (pr *packagesRepository) getCacheDir() string {
 	return utils.GetAhkpmDir() + `\cache`
 }
 
-func (pr *packagesRepository) getPackageCacheDir(depName string) string {
-	return pr.getCacheDir() + `\` + depName
+// linkSnapshot creates a directory at path which links to the files in the
+// snapshot. With SymlinkInstallMode each top-level file and directory is a
+// symbolic link, while with HardlinkInstallMode each file is a hard link. Any
+// ahkpm-modules directory is always copied, since dependencies are installed
+// within it.
+func linkSnapshot(snapshotDir string, path string, installMode string) error {
+	snapshotDir, err := filepath.Abs(snapshotDir)
+	if err != nil {
+		return err
+	}
+
+	if installMode == SymlinkInstallMode {
+		err = os.MkdirAll(path, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(snapshotDir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			source := filepath.Join(snapshotDir, entry.Name())
+			target := filepath.Join(path, entry.Name())
+			if entry.Name() == "ahkpm-modules" {
+				err = copy.Copy(source, target, installCopyOptions)
+			} else {
+				err = os.Symlink(source, target)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return filepath.WalkDir(snapshotDir, func(source string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(snapshotDir, source)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(path, relPath)
+
+		if relPath == "ahkpm-modules" {
+			err = copy.Copy(source, target, installCopyOptions)
+			if err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		return os.Link(source, target)
+	})
+}
+
+func (pr *packagesRepository) getReposDir() string {
+	return pr.getCacheDir() + `\repos`
+}
+
+// getRepositoryDir returns the directory of the bare clone of a repository
+func (pr *packagesRepository) getRepositoryDir(repoName string) string {
+	return pr.getReposDir() + `\` + repoName
+}
+
+// getSnapshotsDir returns the directory containing every snapshot of a package
+func (pr *packagesRepository) getSnapshotsDir(depName string) string {
+	return pr.getCacheDir() + `\snapshots\` + depName
+}
+
+// getSnapshotDir returns the directory containing the files of a package at
+// a commit. Snapshots are never modified once they have been extracted.
+func (pr *packagesRepository) getSnapshotDir(depName string, sha string) string {
+	return pr.getSnapshotsDir(depName) + `\` + sha
 }
 
-func (pr *packagesRepository) ensurePackageIsUpToDate(depName string) (*git.Repository, bool, error) {
-	packageCacheDir := pr.getPackageCacheDir(depName)
+// ensurePackageIsUpToDate clones the package if it is not already in the
+// cache, and otherwise fetches its latest branches and tags once per run
+func (pr *packagesRepository) ensurePackageIsUpToDate(depName string) (*git.Repository, error) {
+	repo, err := pr.ensureRepository(depName, getGitUrl(depName))
+	if err != nil {
+		return nil, err
+	}
+	markUsed(pr.getRepositoryDir(depName))
 
-	err := os.MkdirAll(packageCacheDir, os.ModePerm)
+	// Only hit the network once per package per run
+	if session.isFetched(depName) {
+		return repo, nil
+	}
+	offlineMode, err := isOffline()
 	if err != nil {
-		return nil, false, errors.New("Error creating package cache directory")
+		return nil, err
+	}
+	if offlineMode {
+		return repo, nil
 	}
 
-	packageCloneAlreadyExisted, err := utils.FileExists(packageCacheDir + `\.git`)
+	err = pr.fetch(depName, repo)
 	if err != nil {
-		return nil, false, errors.New("Error checking if package was cloned")
+		return nil, err
 	}
 
-	if !packageCloneAlreadyExisted {
-		// Clone the repository into the cache directory
-		_, err := git.PlainClone(packageCacheDir, false, &git.CloneOptions{
-			URL:               getGitUrl(depName),
-			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-		})
+	return repo, nil
+}
+
+// removeLegacyCache removes the package directories left in the cache by
+// versions of ahkpm which stored a worktree per package, rather than bare
+// clones and snapshots. This happens only once, before the first repository is
+// cloned into the new layout.
+func (pr *packagesRepository) removeLegacyCache() error {
+	_, err := os.Stat(pr.getReposDir())
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(pr.getCacheDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("Error reading package cache")
+	}
+	for _, entry := range entries {
+		if entry.Name() == "repos" || entry.Name() == "snapshots" {
+			continue
+		}
+		err = pr.removeAll(filepath.Join(pr.getCacheDir(), entry.Name()))
 		if err != nil {
-			message := "Error cloning package"
-			if err.Error() == "authentication required" {
-				message = "Error downloading package " + depName + ". Are you sure that package exists?"
-			}
-			return nil, packageCloneAlreadyExisted, errors.New(message)
+			return errors.New("Error removing old package cache " + entry.Name())
 		}
 	}
+	return nil
+}
 
-	repo, err := git.PlainOpen(packageCacheDir)
+// ensureRepository opens the bare clone of a repository in the cache, cloning
+// it first if needed
+func (pr *packagesRepository) ensureRepository(repoName string, url string) (*git.Repository, error) {
+	repoDir := pr.getRepositoryDir(repoName)
+
+	repo, err := git.PlainOpen(repoDir)
+	if err == nil {
+		return repo, nil
+	}
+	if err != git.ErrRepositoryNotExists {
+		return nil, errors.New("Error opening package " + repoName)
+	}
+	offlineMode, err := isOffline()
 	if err != nil {
-		return nil, packageCloneAlreadyExisted, errors.New("Error opening package")
+		return nil, err
+	}
+	if offlineMode {
+		return nil, NotInCacheError{Name: repoName}
 	}
 
-	err = repo.Fetch(&git.FetchOptions{})
-	if err != nil && err.Error() != "already up-to-date" {
-		return nil, packageCloneAlreadyExisted, errors.New("Error fetching package")
+	err = pr.removeLegacyCache()
+	if err != nil {
+		return nil, err
 	}
 
-	return repo, packageCloneAlreadyExisted, nil
+	err = os.MkdirAll(filepath.Dir(repoDir), os.ModePerm)
+	if err != nil {
+		return nil, errors.New("Error creating package cache directory")
+	}
+
+	unlock, err := utils.LockFile(repoDir + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Another process may have cloned the repository while we waited
+	repo, err = git.PlainOpen(repoDir)
+	if err == nil {
+		return repo, nil
+	}
+
+	// Clone into a temporary directory first, so that an interrupted clone
+	// never leaves a broken repository behind
+	tempDir, err := os.MkdirTemp(filepath.Dir(repoDir), ".clone-")
+	if err != nil {
+		return nil, errors.New("Error creating package cache directory")
+	}
+	defer utils.ForceRemoveAll(tempDir)
+
+	tempRepo, err := git.PlainInit(tempDir, true)
+	if err != nil {
+		return nil, errors.New("Error cloning package")
+	}
+	_, err = tempRepo.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{url},
+		// Mirror branches and tags so that they can be resolved by name
+		Fetch: []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"},
+	})
+	if err != nil {
+		return nil, errors.New("Error cloning package")
+	}
+	err = tempRepo.Fetch(&git.FetchOptions{RemoteName: "origin"})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		message := "Error cloning package"
+		if err.Error() == "authentication required" {
+			message = "Error downloading package " + repoName + ". Are you sure that package exists?"
+		}
+		return nil, errors.New(message)
+	}
+
+	err = os.Rename(tempDir, repoDir)
+	if err != nil {
+		return nil, errors.New("Error saving package to cache")
+	}
+
+	// A fresh clone is already up to date
+	session.markFetched(repoName)
+
+	repo, err = git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, errors.New("Error opening package " + repoName)
+	}
+	return repo, nil
 }
 
-func (pr *packagesRepository) ensurePackageIsReady(depName string, depVersionString string) error {
-	repo, previouslyCloned, err := pr.ensurePackageIsUpToDate(depName)
+// fetch updates the branches and tags of a repository in the cache
+func (pr *packagesRepository) fetch(repoName string, repo *git.Repository) error {
+	unlock, err := utils.LockFile(pr.getRepositoryDir(repoName) + ".lock")
 	if err != nil {
 		return err
 	}
+	defer unlock()
+
+	err = repo.Fetch(&git.FetchOptions{RemoteName: "origin", Force: true})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return errors.New("Error fetching package " + repoName)
+	}
+	session.markFetched(repoName)
+	return nil
+}
 
-	worktree, err := repo.Worktree()
+// ensureSnapshot extracts the files of the package at the commit into the
+// snapshot directory for that commit, unless it was already extracted, and
+// returns the snapshot directory
+func (pr *packagesRepository) ensureSnapshot(depName string, sha string) (string, error) {
+	snapshotDir := pr.getSnapshotDir(depName, sha)
+
+	exists, err := utils.FileExists(snapshotDir)
 	if err != nil {
-		return errors.New("Error getting worktree")
+		return "", errors.New("Error checking package cache")
+	}
+	if exists {
+		markUsed(snapshotDir)
+		return snapshotDir, nil
 	}
 
-	if previouslyCloned {
-		errorMessage := "Problem fetching latest updates to package " + depName + ". Continuing from local cache."
+	repo, err := pr.ensureRepository(depName, getGitUrl(depName))
+	if err != nil {
+		return "", err
+	}
+	hash, err := pr.ensureCommit(depName, repo, sha)
+	if err != nil {
+		return "", err
+	}
 
-		branches, err := repo.Branches()
-		if err != nil {
-			fmt.Println(errorMessage)
+	err = pr.extractSnapshot(repo, depName, getGitUrl(depName), hash, snapshotDir)
+	if err != nil {
+		return "", err
+	}
+	return snapshotDir, nil
+}
+
+// ensureCommit resolves the revision, fetching the repository first if it
+// does not have the commit yet
+func (pr *packagesRepository) ensureCommit(repoName string, repo *git.Repository, revision string) (plumbing.Hash, error) {
+	hash, err := resolveRevision(repo, repoName, revision)
+	if err == nil || session.isFetched(repoName) {
+		return hash, err
+	}
+	offlineMode, err := isOffline()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if offlineMode {
+		return plumbing.ZeroHash, NotInCacheError{Name: repoName, Version: revision}
+	}
+
+	err = pr.fetch(repoName, repo)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return resolveRevision(repo, repoName, revision)
+}
+
+// markUsed records that a cached repository or snapshot was used, by updating
+// its modification time. The times are used to find packages which haven't
+// been used recently.
+func markUsed(path string) {
+	now := time.Now()
+	// Failing to record the time only affects cache cleanup, so it is ignored
+	_ = os.Chtimes(path, now, now)
+}
+
+// extractSnapshot writes the files of the commit, including those of its
+// submodules, to snapshotDir. Files are extracted to a temporary directory
+// first and then moved into place, so other processes never see a partial
+// snapshot. The caller must hold the package lock for repoName.
+func (pr *packagesRepository) extractSnapshot(repo *git.Repository, repoName string, url string, hash plumbing.Hash, snapshotDir string) error {
+	err := os.MkdirAll(filepath.Dir(snapshotDir), os.ModePerm)
+	if err != nil {
+		return errors.New("Error creating package cache directory")
+	}
+
+	tempDir, err := os.MkdirTemp(filepath.Dir(snapshotDir), ".extract-")
+	if err != nil {
+		return errors.New("Error creating package cache directory")
+	}
+	defer utils.ForceRemoveAll(tempDir)
+
+	err = pr.extractCommit(repo, url, hash, tempDir, []string{repoName})
+	if err != nil {
+		return err
+	}
+
+	// Linked packages share the snapshot's files, so it is made read-only
+	// to keep edits in one project from changing every other project
+	err = utils.MakeReadOnly(tempDir)
+	if err != nil {
+		return errors.New("Error saving package to cache")
+	}
+
+	err = os.Rename(tempDir, snapshotDir)
+	if err != nil {
+		// Another process may have extracted the same snapshot first
+		exists, existsErr := utils.FileExists(snapshotDir)
+		if existsErr == nil && exists {
+			return nil
 		}
+		return errors.New("Error saving package to cache")
+	}
+	return nil
+}
 
-		// Brute forcing our way to updating all branches. Ideally we'd only
-		// do this for the branch we're checking out, but determining whether
-		// we're checking out a branch requires larger scale changes.
-		err = branches.ForEach(func(branch *plumbing.Reference) error {
-			err = worktree.Checkout(&git.CheckoutOptions{
-				Branch: branch.Name(),
-				Force:  true, // Ignore changes in the working tree
-			})
-			if err != nil {
-				return err
-			}
-			return worktree.Pull(&git.PullOptions{
-				RemoteName:    "origin",
-				ReferenceName: branch.Name(),
-			})
-		})
+// extractCommit writes the files of the commit to dir, then does the same for
+// each of its submodules. lockedNames lists the repositories whose package
+// locks are already held by the caller.
+func (pr *packagesRepository) extractCommit(repo *git.Repository, url string, hash plumbing.Hash, dir string, lockedNames []string) error {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return errors.New("Error reading commit " + hash.String())
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return errors.New("Error reading commit " + hash.String())
+	}
 
-		if err != nil && err != git.NoErrAlreadyUpToDate {
-			fmt.Println(errorMessage)
+	err = tree.Files().ForEach(func(file *object.File) error {
+		return writeFile(file, filepath.Join(dir, filepath.FromSlash(file.Name)))
+	})
+	if err != nil {
+		return errors.New("Error extracting files for commit " + hash.String())
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		path, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.New("Error extracting files for commit " + hash.String())
+		}
+		if entry.Mode != filemode.Submodule {
+			continue
+		}
+
+		err = pr.extractSubmodule(tree, url, path, entry.Hash, filepath.Join(dir, filepath.FromSlash(path)), lockedNames)
+		if err != nil {
+			return err
 		}
 	}
 
-	hash, err := repo.ResolveRevision(plumbing.Revision(depVersionString))
+	return nil
+}
+
+// extractSubmodule writes the files of the submodule at path, as listed in
+// the .gitmodules file of the tree, to dir
+func (pr *packagesRepository) extractSubmodule(tree *object.Tree, parentUrl string, path string, hash plumbing.Hash, dir string, lockedNames []string) error {
+	gitmodulesFile, err := tree.File(".gitmodules")
 	if err != nil {
-		message := "Error resolving revision"
-		if err.Error() == "reference not found" {
-			message = "Could not find version " + depVersionString + " for package " + depName + ". Are you sure that version exists?"
+		return errors.New("Error finding submodule " + path)
+	}
+	contents, err := gitmodulesFile.Contents()
+	if err != nil {
+		return errors.New("Error finding submodule " + path)
+	}
+	modules := config.NewModules()
+	err = modules.Unmarshal([]byte(contents))
+	if err != nil {
+		return errors.New("Error reading .gitmodules")
+	}
+
+	for _, submodule := range modules.Submodules {
+		if submodule.Path != path {
+			continue
+		}
+
+		url, err := resolveSubmoduleUrl(parentUrl, submodule.URL)
+		if err != nil {
+			return err
+		}
+		repoName := getRepositoryNameFromUrl(url)
+		// Package locks are not reentrant, so a submodule which refers back to
+		// a repository being extracted must not lock it again
+		if !slices.Contains(lockedNames, repoName) {
+			defer packageLocks.Lock(repoName)()
+			lockedNames = append(lockedNames[:len(lockedNames):len(lockedNames)], repoName)
+		}
+
+		repo, err := pr.ensureRepository(repoName, url)
+		if err != nil {
+			return err
 		}
-		return errors.New(message)
+		_, err = pr.ensureCommit(repoName, repo, hash.String())
+		if err != nil {
+			return err
+		}
+		return pr.extractCommit(repo, url, hash, dir, lockedNames)
 	}
 
-	err = worktree.Checkout(&git.CheckoutOptions{
-		Hash:  (*hash),
-		Force: true, // Ignore changes in the working tree
-	})
+	return errors.New("Error finding submodule " + path)
+}
+
+// writeFile writes the contents of a file from a git tree to path
+func writeFile(file *object.File, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	reader, err := file.Reader()
 	if err != nil {
-		fmt.Println(err.Error())
-		return errors.New("Error checking out version")
+		return err
 	}
+	defer reader.Close()
 
-	submodules, err := worktree.Submodules()
+	perm := fs.FileMode(0444)
+	if file.Mode == filemode.Executable {
+		perm = 0555
+	}
+	output, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
 	if err != nil {
-		return errors.New("Error getting submodules")
+		return err
 	}
+	defer output.Close()
+
+	_, err = io.Copy(output, reader)
+	return err
+}
 
-	for _, sub := range submodules {
-		err := sub.Update(&git.SubmoduleUpdateOptions{})
-		// In the event of a connection issue we continue from the local copy
-		if err != nil && !strings.Contains(err.Error(), "no such host") {
-			return errors.New("Error updating submodule")
+// resolveRevision returns the commit which the branch, tag or commit SHA
+// refers to
+func resolveRevision(repo *git.Repository, depName string, revision string) (plumbing.Hash, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		message := "Error resolving revision"
+		if err.Error() == "reference not found" || err == plumbing.ErrObjectNotFound {
+			message = "Could not find version " + revision + " for package " + depName + ". Are you sure that version exists?"
 		}
+		return plumbing.ZeroHash, errors.New(message)
 	}
-	return nil
+	return *hash, nil
+}
+
+// resolveSubmoduleUrl resolves submodule URLs which are relative to the URL of
+// the parent repository, such as ../other-repo.git
+func resolveSubmoduleUrl(parentUrl string, url string) (string, error) {
+	if !strings.HasPrefix(url, "./") && !strings.HasPrefix(url, "../") {
+		return url, nil
+	}
+
+	// The host part of the URL can never be removed by ..
+	rootLength := 0
+	if index := strings.Index(parentUrl, "://"); index >= 0 {
+		rootLength = index + len("://")
+		if hostEnd := strings.Index(parentUrl[rootLength:], "/"); hostEnd >= 0 {
+			rootLength += hostEnd
+		} else {
+			rootLength = len(parentUrl)
+		}
+	} else if index := strings.Index(parentUrl, ":"); index >= 0 {
+		// scp-like syntax, such as git@github.com:user/repo.git
+		rootLength = index + 1
+	}
+
+	base := strings.TrimSuffix(parentUrl, "/")
+	for _, part := range strings.Split(url, "/") {
+		switch part {
+		case ".":
+		case "..":
+			index := strings.LastIndex(base, "/")
+			if index < rootLength {
+				return "", fmt.Errorf("Submodule URL %s goes above the URL of its parent repository, %s", url, parentUrl)
+			}
+			base = base[:index]
+		default:
+			base += "/" + part
+		}
+	}
+	return base, nil
+}
+
+// getRepositoryNameFromUrl converts a git URL into a name suitable for a
+// cache directory, such as github.com/user/repo
+func getRepositoryNameFromUrl(url string) string {
+	name := url
+	if index := strings.Index(name, "://"); index >= 0 {
+		name = name[index+len("://"):]
+	} else if index := strings.Index(name, ":"); index >= 0 {
+		// scp-like syntax, such as git@github.com:user/repo.git
+		name = name[:index] + "/" + name[index+1:]
+	}
+	if index := strings.Index(name, "@"); index >= 0 && index < strings.Index(name, "/") {
+		name = name[index+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(name, "/"), ".git")
 }
 
 func getGitUrl(packageName string) string {
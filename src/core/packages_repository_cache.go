@@ -0,0 +1,619 @@
+package core
+
+import (
+	"ahkpm/src/utils"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// CachedPackage describes a package repository in the cache
+type CachedPackage struct {
+	Name string `json:"name"`
+	// Size is the number of bytes used by the repository and its snapshots
+	Size int64 `json:"size"`
+	// LastUsed is when the package was last installed or resolved
+	LastUsed time.Time `json:"lastUsed"`
+	// Tags lists the repository's tags, with semantic versions first from
+	// highest to lowest
+	Tags []string `json:"tags"`
+	// Snapshots lists the commits whose files have been extracted
+	Snapshots []CachedSnapshot `json:"snapshots"`
+}
+
+// CachedSnapshot describes the extracted files of a package at one commit
+type CachedSnapshot struct {
+	SHA      string    `json:"sha"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// CacheIssue is a problem found in the cache by VerifyCache
+type CacheIssue struct {
+	Name    string `json:"name"`
+	Problem string `json:"problem"`
+	// Fixed is true if the problem was repaired by cloning the repository
+	// again or removing a snapshot so that it will be extracted again
+	Fixed bool `json:"fixed"`
+}
+
+// CacheLimits controls when CollectCacheGarbage evicts packages from the
+// cache. A zero value means there is no limit.
+type CacheLimits struct {
+	// MaxSize is the number of bytes the cache may use
+	MaxSize int64
+	// MaxAge is how long a package may go unused before it is evicted
+	MaxAge time.Duration
+}
+
+func (l CacheLimits) IsEmpty() bool {
+	return l.MaxSize <= 0 && l.MaxAge <= 0
+}
+
+// CacheEviction is a package, or one snapshot of a package, which is removed
+// from the cache by CollectCacheGarbage
+type CacheEviction struct {
+	Name string `json:"name"`
+	// SHA is the commit of the evicted snapshot, or empty if the whole package
+	// was evicted
+	SHA      string    `json:"sha,omitempty"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+func (pr *packagesRepository) GetCacheDir() string {
+	return pr.getCacheDir()
+}
+
+// ListCache returns every package repository in the cache, sorted by name
+func (pr *packagesRepository) ListCache() ([]CachedPackage, error) {
+	repoNames, err := pr.getCachedRepositoryNames()
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]CachedPackage, 0, len(repoNames))
+	for _, repoName := range repoNames {
+		cachedPackage, err := pr.getCachedPackage(repoName)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, cachedPackage)
+	}
+	return packages, nil
+}
+
+func (pr *packagesRepository) getCachedPackage(repoName string) (CachedPackage, error) {
+	defer packageLocks.Lock(repoName)()
+
+	repoDir := pr.getRepositoryDir(repoName)
+	size, err := getDirectorySize(repoDir)
+	if err != nil {
+		return CachedPackage{}, err
+	}
+	info, err := os.Stat(repoDir)
+	if err != nil {
+		return CachedPackage{}, err
+	}
+
+	cachedPackage := CachedPackage{
+		Name:      repoName,
+		Size:      size,
+		LastUsed:  info.ModTime(),
+		Tags:      []string{},
+		Snapshots: []CachedSnapshot{},
+	}
+
+	repo, err := git.PlainOpen(repoDir)
+	if err == nil {
+		cachedPackage.Tags = getSortedTags(repo)
+	}
+
+	snapshots, err := pr.getCachedSnapshots(repoName)
+	if err != nil {
+		return CachedPackage{}, err
+	}
+	for _, snapshot := range snapshots {
+		cachedPackage.Size += snapshot.Size
+		if snapshot.LastUsed.After(cachedPackage.LastUsed) {
+			cachedPackage.LastUsed = snapshot.LastUsed
+		}
+	}
+	cachedPackage.Snapshots = snapshots
+
+	return cachedPackage, nil
+}
+
+// getCachedSnapshots returns the snapshots extracted for the package
+func (pr *packagesRepository) getCachedSnapshots(depName string) ([]CachedSnapshot, error) {
+	entries, err := os.ReadDir(pr.getSnapshotsDir(depName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []CachedSnapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]CachedSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		// Skip snapshots which are still being extracted
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		snapshotDir := pr.getSnapshotDir(depName, entry.Name())
+		size, err := getDirectorySize(snapshotDir)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(snapshotDir)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, CachedSnapshot{
+			SHA:      entry.Name(),
+			Size:     size,
+			LastUsed: info.ModTime(),
+		})
+	}
+	return snapshots, nil
+}
+
+// getCachedRepositoryNames returns the name of every repository in the cache.
+// Repositories are usually three levels deep, such as github.com/user/repo,
+// but submodules cloned from other hosts may be nested differently.
+func (pr *packagesRepository) getCachedRepositoryNames() ([]string, error) {
+	reposDir := pr.getReposDir()
+	names := make([]string, 0)
+	err := filepath.WalkDir(reposDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == reposDir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == reposDir {
+			return nil
+		}
+		// Skip clones which are still in progress
+		if strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		isRepo, err := utils.FileExists(filepath.Join(path, "HEAD"))
+		if err != nil {
+			return err
+		}
+		if !isRepo {
+			return nil
+		}
+
+		name, err := filepath.Rel(reposDir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// AddToCache downloads the package and extracts its files at the version, so
+// that it can later be installed without accessing the network. It returns
+// the SHA of the cached commit.
+func (pr *packagesRepository) AddToCache(dep Dependency) (string, error) {
+	sha, err := pr.GetResolvedDependencySHA(dep)
+	if err != nil {
+		return "", err
+	}
+
+	defer packageLocks.Lock(dep.Name())()
+	_, err = pr.ensureSnapshot(dep.Name(), sha)
+	if err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+// RemoveFromCache removes the package's repository and snapshots from the
+// cache
+func (pr *packagesRepository) RemoveFromCache(depName string) error {
+	defer packageLocks.Lock(depName)()
+
+	dirs := []string{pr.getRepositoryDir(depName), pr.getSnapshotsDir(depName)}
+
+	isCached := false
+	for _, dir := range dirs {
+		exists, err := utils.FileExists(dir)
+		if err != nil {
+			return err
+		}
+		isCached = isCached || exists
+	}
+	if !isCached {
+		return errors.New(depName + " is not in the cache")
+	}
+
+	unlock, err := utils.LockFile(pr.getRepositoryDir(depName) + ".lock")
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		err = pr.removeAll(dir)
+		if err != nil {
+			break
+		}
+	}
+	unlock()
+	if err != nil {
+		return errors.New("Error removing " + depName + " from the cache")
+	}
+	session.forget(depName)
+
+	for _, dir := range dirs {
+		err := removeEmptyParents(dir, pr.getCacheDir())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyCache checks that every object reachable from the branches and tags
+// of each repository can be read, and that each snapshot matches its commit.
+// Corrupt repositories are cloned again, and corrupt snapshots are removed so
+// that they are extracted again when next needed.
+func (pr *packagesRepository) VerifyCache() ([]CacheIssue, error) {
+	repoNames, err := pr.getCachedRepositoryNames()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]CacheIssue, 0)
+	for _, repoName := range repoNames {
+		repoIssues, err := pr.verifyCachedPackage(repoName)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, repoIssues...)
+	}
+	return issues, nil
+}
+
+func (pr *packagesRepository) verifyCachedPackage(repoName string) ([]CacheIssue, error) {
+	defer packageLocks.Lock(repoName)()
+
+	issues := make([]CacheIssue, 0)
+	repoDir := pr.getRepositoryDir(repoName)
+
+	repo, err := git.PlainOpen(repoDir)
+	if err == nil {
+		err = checkRepository(repo)
+	}
+	if err != nil {
+		issue := CacheIssue{Name: repoName, Problem: "repository is corrupt: " + err.Error()}
+		repo, err = pr.recloneRepository(repoName, repo)
+		issue.Fixed = err == nil
+		if err != nil {
+			issue.Problem += ". Unable to clone it again: " + err.Error()
+		}
+		issues = append(issues, issue)
+		if repo == nil {
+			return issues, nil
+		}
+	}
+
+	snapshots, err := pr.getCachedSnapshots(repoName)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		problem := pr.checkSnapshot(repo, repoName, snapshot.SHA)
+		if problem == "" {
+			continue
+		}
+		err := pr.removeAll(pr.getSnapshotDir(repoName, snapshot.SHA))
+		issues = append(issues, CacheIssue{
+			Name:    repoName + "@" + snapshot.SHA,
+			Problem: problem,
+			Fixed:   err == nil,
+		})
+	}
+
+	return issues, nil
+}
+
+// CollectCacheGarbage evicts the least recently used packages from the cache
+// until it is within the limits. Packages in protected are never evicted,
+// though their snapshots of other commits may be. It returns what was evicted.
+func (pr *packagesRepository) CollectCacheGarbage(limits CacheLimits, protected []ResolvedDependency) ([]CacheEviction, error) {
+	if limits.IsEmpty() {
+		return []CacheEviction{}, nil
+	}
+
+	cachedPackages, err := pr.ListCache()
+	if err != nil {
+		return nil, err
+	}
+
+	evictions := SelectCacheEvictions(cachedPackages, protected, limits, time.Now())
+	for i, eviction := range evictions {
+		if eviction.SHA == "" {
+			err = pr.RemoveFromCache(eviction.Name)
+		} else {
+			unlock := packageLocks.Lock(eviction.Name)
+			err = pr.removeSnapshot(eviction.Name, eviction.SHA)
+			unlock()
+		}
+		if err != nil {
+			return evictions[:i], err
+		}
+	}
+	return evictions, nil
+}
+
+// SelectCacheEvictions chooses what to evict from the cached packages, from
+// least to most recently used, so that nothing left is older than
+// limits.MaxAge and the total size is at most limits.MaxSize. Packages which
+// are in protected are never chosen. Only their snapshots of commits other
+// than the protected ones may be.
+func SelectCacheEvictions(
+	cachedPackages []CachedPackage,
+	protected []ResolvedDependency,
+	limits CacheLimits,
+	now time.Time,
+) []CacheEviction {
+	protectedSHAs := make(map[string]map[string]bool)
+	for _, dep := range protected {
+		if protectedSHAs[dep.Name] == nil {
+			protectedSHAs[dep.Name] = make(map[string]bool)
+		}
+		protectedSHAs[dep.Name][dep.SHA] = true
+	}
+
+	var totalSize int64
+	candidates := make([]CacheEviction, 0)
+	for _, cachedPackage := range cachedPackages {
+		totalSize += cachedPackage.Size
+
+		shas, isProtected := protectedSHAs[cachedPackage.Name]
+		if !isProtected {
+			candidates = append(candidates, CacheEviction{
+				Name:     cachedPackage.Name,
+				Size:     cachedPackage.Size,
+				LastUsed: cachedPackage.LastUsed,
+			})
+			continue
+		}
+		for _, snapshot := range cachedPackage.Snapshots {
+			if !shas[snapshot.SHA] {
+				candidates = append(candidates, CacheEviction{
+					Name:     cachedPackage.Name,
+					SHA:      snapshot.SHA,
+					Size:     snapshot.Size,
+					LastUsed: snapshot.LastUsed,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].LastUsed.Before(candidates[j].LastUsed)
+	})
+
+	evictions := make([]CacheEviction, 0)
+	for _, candidate := range candidates {
+		isExpired := limits.MaxAge > 0 && now.Sub(candidate.LastUsed) > limits.MaxAge
+		isOverSize := limits.MaxSize > 0 && totalSize > limits.MaxSize
+		// Every later candidate was used more recently, so none of them
+		// have expired either
+		if !isExpired && !isOverSize {
+			break
+		}
+		evictions = append(evictions, candidate)
+		totalSize -= candidate.Size
+	}
+	return evictions
+}
+
+// removeSnapshot removes the files of the package at one commit from the
+// cache. They are extracted again when next needed. The caller must hold the
+// package lock for depName.
+func (pr *packagesRepository) removeSnapshot(depName string, sha string) error {
+	snapshotDir := pr.getSnapshotDir(depName, sha)
+	err := pr.removeAll(snapshotDir)
+	if err != nil {
+		return errors.New("Error removing " + depName + "@" + sha + " from the cache")
+	}
+	return removeEmptyParents(snapshotDir, pr.getCacheDir())
+}
+
+// checkRepository reads every commit reachable from the repository's
+// references, along with the trees and files of the commits they point to
+func checkRepository(repo *git.Repository) error {
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	return refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		hash, err := repo.ResolveRevision(plumbing.Revision(ref.Name().String()))
+		if err != nil {
+			return errors.New("unable to resolve " + ref.Name().Short())
+		}
+		commit, err := repo.CommitObject(*hash)
+		if err != nil {
+			return errors.New("unable to read commit " + hash.String())
+		}
+
+		tree, err := commit.Tree()
+		if err != nil {
+			return errors.New("unable to read tree of commit " + hash.String())
+		}
+		err = tree.Files().ForEach(func(file *object.File) error {
+			_, err := file.Contents()
+			return err
+		})
+		if err != nil {
+			return errors.New("unable to read files of commit " + hash.String())
+		}
+
+		history, err := repo.Log(&git.LogOptions{From: *hash})
+		if err != nil {
+			return errors.New("unable to read history of " + ref.Name().Short())
+		}
+		err = history.ForEach(func(*object.Commit) error { return nil })
+		if err != nil {
+			return errors.New("unable to read history of " + ref.Name().Short())
+		}
+		return nil
+	})
+}
+
+// checkSnapshot extracts the commit again and compares it with the snapshot,
+// returning a description of any problem
+func (pr *packagesRepository) checkSnapshot(repo *git.Repository, repoName string, sha string) string {
+	hash, err := repo.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return "commit is missing from the repository"
+	}
+
+	tempDir, err := os.MkdirTemp(pr.getSnapshotsDir(repoName), ".verify-")
+	if err != nil {
+		return "unable to extract commit: " + err.Error()
+	}
+	defer utils.ForceRemoveAll(tempDir)
+
+	err = pr.extractCommit(repo, getRemoteUrl(repo, repoName), *hash, tempDir, []string{repoName})
+	if err != nil {
+		return "unable to extract commit: " + err.Error()
+	}
+
+	expected, err := utils.HashDirectory(tempDir)
+	if err != nil {
+		return "unable to extract commit: " + err.Error()
+	}
+	actual, err := utils.HashDirectory(pr.getSnapshotDir(repoName, sha))
+	if err != nil {
+		return "unable to read snapshot: " + err.Error()
+	}
+	if actual != expected {
+		return "files have been modified"
+	}
+	return ""
+}
+
+// recloneRepository removes the repository from the cache and clones it again
+// from the same URL
+func (pr *packagesRepository) recloneRepository(repoName string, repo *git.Repository) (*git.Repository, error) {
+	url := getGitUrl(repoName)
+	if repo != nil {
+		url = getRemoteUrl(repo, repoName)
+	}
+
+	err := pr.removeAll(pr.getRepositoryDir(repoName))
+	if err != nil {
+		return nil, err
+	}
+	session.forget(repoName)
+	return pr.ensureRepository(repoName, url)
+}
+
+// getRemoteUrl returns the URL the repository was cloned from
+func getRemoteUrl(repo *git.Repository, repoName string) string {
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return getGitUrl(repoName)
+	}
+	return remote.Config().URLs[0]
+}
+
+// getSortedTags returns the names of the repository's tags, with semantic
+// versions first from highest to lowest, followed by any other tags
+func getSortedTags(repo *git.Repository) []string {
+	tagIter, err := repo.Tags()
+	if err != nil {
+		return []string{}
+	}
+
+	versions := make([]*semver.Version, 0)
+	others := make([]string, 0)
+	_ = tagIter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		version, err := semver.StrictNewVersion(name)
+		if err == nil {
+			versions = append(versions, version)
+		} else {
+			others = append(others, name)
+		}
+		return nil
+	})
+
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+	sort.Strings(others)
+
+	tags := make([]string, 0, len(versions)+len(others))
+	for _, version := range versions {
+		tags = append(tags, version.Original())
+	}
+	return append(tags, others...)
+}
+
+// getDirectorySize returns the total size of the files in dir
+func getDirectorySize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// removeEmptyParents removes the directories containing path, up to but not
+// including root, for as long as they are empty
+func removeEmptyParents(path string, root string) error {
+	for dir := filepath.Dir(path); len(dir) > len(root); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return nil
+		}
+		err = os.Remove(dir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
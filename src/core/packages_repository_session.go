@@ -0,0 +1,94 @@
+package core
+
+import (
+	"strings"
+	"sync"
+)
+
+// repositorySession records the git operations which have already been
+// performed during the current run of ahkpm, so that each package is fetched
+// from the network at most once per run.
+type repositorySession struct {
+	mu sync.Mutex
+	// fetched contains the packages which have been cloned or fetched
+	fetched map[string]bool
+	// tags maps each package to the names of its tags
+	tags map[string][]string
+	// shas maps a package name and version specifier to the resolved commit
+	shas map[string]string
+}
+
+// session is shared by all packagesRepository instances, since they all
+// operate on the same cache directory
+var session = newRepositorySession()
+
+func newRepositorySession() *repositorySession {
+	return &repositorySession{
+		fetched: make(map[string]bool),
+		tags:    make(map[string][]string),
+		shas:    make(map[string]string),
+	}
+}
+
+// reset forgets everything recorded so far. It is used when the cache is cleared.
+func (s *repositorySession) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fetched = make(map[string]bool)
+	s.tags = make(map[string][]string)
+	s.shas = make(map[string]string)
+}
+
+// forget removes everything recorded about a package. It is used when the
+// package is removed from the cache.
+func (s *repositorySession) forget(depName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.fetched, depName)
+	delete(s.tags, depName)
+	for key := range s.shas {
+		if strings.HasPrefix(key, depName+"@") {
+			delete(s.shas, key)
+		}
+	}
+}
+
+func (s *repositorySession) isFetched(depName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetched[depName]
+}
+
+func (s *repositorySession) markFetched(depName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetched[depName] = true
+}
+
+func (s *repositorySession) getTags(depName string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags, ok := s.tags[depName]
+	return tags, ok
+}
+
+func (s *repositorySession) setTags(depName string, tags []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags[depName] = tags
+}
+
+func (s *repositorySession) getSHA(dep Dependency) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sha, ok := s.shas[dep.Name()+"@"+dep.Version().String()]
+	return sha, ok
+}
+
+func (s *repositorySession) setSHA(dep Dependency, sha string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shas[dep.Name()+"@"+dep.Version().String()] = sha
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVersionsFetchesOncePerSession(t *testing.T) {
+	t.Setenv("userprofile", t.TempDir())
+	session = newRepositorySession()
+	origin := createOriginRepository(t)
+	commitToOrigin(t, origin, "1.0.0")
+	pr := cloneOriginRepository(t, "github.com/joshuacc/session", origin)
+
+	tags, err := pr.GetVersions("github.com/joshuacc/session")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0"}, tags)
+
+	// Tags added after the first lookup are not seen until the next session,
+	// and the cached repository is not even opened again
+	commitToOrigin(t, origin, "1.1.0")
+	restore := hideCachedRepository(t, pr, "github.com/joshuacc/session")
+
+	tags, err = pr.GetVersions("github.com/joshuacc/session")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0"}, tags)
+
+	restore()
+
+	session = newRepositorySession()
+
+	tags, err = pr.GetVersions("github.com/joshuacc/session")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0", "1.1.0"}, tags)
+}
+
+func TestGetResolvedDependencySHAFetchesOncePerSession(t *testing.T) {
+	t.Setenv("userprofile", t.TempDir())
+	session = newRepositorySession()
+	origin := createOriginRepository(t)
+	firstSHA := commitToOrigin(t, origin, "1.0.0")
+	pr := cloneOriginRepository(t, "github.com/joshuacc/session", origin)
+	dep := NewDependency("github.com/joshuacc/session", NewVersion(Branch, "main"))
+
+	sha, err := pr.GetResolvedDependencySHA(dep)
+	assert.NoError(t, err)
+	assert.Equal(t, firstSHA, sha)
+
+	// Commits made after the first lookup are not seen until the next session,
+	// and the cached repository is not even opened again
+	secondSHA := commitToOrigin(t, origin, "1.1.0")
+	restore := hideCachedRepository(t, pr, "github.com/joshuacc/session")
+
+	sha, err = pr.GetResolvedDependencySHA(dep)
+	assert.NoError(t, err)
+	assert.Equal(t, firstSHA, sha)
+
+	restore()
+
+	session = newRepositorySession()
+
+	sha, err = pr.GetResolvedDependencySHA(dep)
+	assert.NoError(t, err)
+	assert.Equal(t, secondSHA, sha)
+}
+
+// createOriginRepository creates an empty repository to stand in for a
+// package's remote repository
+func createOriginRepository(t *testing.T) string {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	assert.NoError(t, err)
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))
+	assert.NoError(t, repo.Storer.SetReference(head))
+	return dir
+}
+
+// commitToOrigin adds a commit to the main branch of the origin repository and
+// tags it, returning the SHA of the commit
+func commitToOrigin(t *testing.T, dir string, tag string) string {
+	repo, err := git.PlainOpen(dir)
+	assert.NoError(t, err)
+	worktree, err := repo.Worktree()
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "version.txt"), []byte(tag), 0644))
+	_, err = worktree.Add("version.txt")
+	assert.NoError(t, err)
+	hash, err := worktree.Commit(tag, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+	_, err = repo.CreateTag(tag, hash, nil)
+	assert.NoError(t, err)
+	return hash.String()
+}
+
+// cloneOriginRepository clones the origin repository into the cache under the
+// given package name, so that later fetches for the package are made from it
+func cloneOriginRepository(t *testing.T, name string, origin string) *packagesRepository {
+	pr := &packagesRepository{removeAll: os.RemoveAll}
+	_, err := pr.ensureRepository(name, origin)
+	assert.NoError(t, err)
+	return pr
+}
+
+// hideCachedRepository moves the package's repository out of the cache, so
+// that any attempt to read it fails. The returned function moves it back.
+func hideCachedRepository(t *testing.T, pr *packagesRepository, name string) func() {
+	repoDir := pr.getRepositoryDir(name)
+	hiddenDir := filepath.Join(t.TempDir(), "hidden")
+	assert.NoError(t, os.Rename(repoDir, hiddenDir))
+	return func() {
+		assert.NoError(t, os.Rename(hiddenDir, repoDir))
+	}
+}
@@ -3,8 +3,14 @@ package core_test
 import (
 	. "ahkpm/src/core"
 	"ahkpm/src/utils"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -45,3 +51,131 @@ func TestGetLatestVersionMatchingRangeFromArray(t *testing.T) {
 		}
 	}
 }
+
+func TestOfflineWithEmptyCache(t *testing.T) {
+	t.Setenv("userprofile", t.TempDir())
+	SetOffline(true)
+	defer SetOffline(false)
+
+	_, err := NewPackagesRepository().GetVersions("github.com/joshuacc/not-cached")
+	assert.Equal(t, NotInCacheError{Name: "github.com/joshuacc/not-cached"}, err)
+	assert.EqualError(t, err, "github.com/joshuacc/not-cached is not in the cache, and cannot be downloaded in offline mode")
+}
+
+func TestOfflineFromPopulatedCache(t *testing.T) {
+	t.Setenv("userprofile", t.TempDir())
+	shas := createCachedRepository(t, "github.com/joshuacc/cached", "1.0.0", "1.1.0", "2.0.0", "beta")
+	SetOffline(true)
+	defer SetOffline(false)
+	pr := NewPackagesRepository()
+
+	sha, err := pr.GetResolvedDependencySHA(NewDependency("github.com/joshuacc/cached", NewVersion(SemVerRange, "^1.0.0")))
+	assert.NoError(t, err)
+	assert.Equal(t, shas["1.1.0"], sha)
+
+	sha, err = pr.GetResolvedDependencySHA(NewDependency("github.com/joshuacc/cached", NewVersion(Tag, "beta")))
+	assert.NoError(t, err)
+	assert.Equal(t, shas["beta"], sha)
+
+	sha, err = pr.GetResolvedDependencySHA(NewDependency("github.com/joshuacc/cached", NewVersion(Branch, "main")))
+	assert.NoError(t, err)
+	assert.Equal(t, shas["beta"], sha)
+
+	_, err = pr.GetResolvedDependencySHA(NewDependency("github.com/joshuacc/cached", NewVersion(Branch, "missing")))
+	assert.Equal(t, NotInCacheError{Name: "github.com/joshuacc/cached", Version: "missing"}, err)
+}
+
+// createCachedRepository adds a repository to the cache as if it had been
+// cloned, with a commit for each tag and a main branch at the last commit. It
+// returns the SHA of each tag's commit.
+func createCachedRepository(t *testing.T, name string, tags ...string) map[string]string {
+	workDir := t.TempDir()
+	repo, err := git.PlainInit(workDir, false)
+	assert.NoError(t, err)
+	worktree, err := repo.Worktree()
+	assert.NoError(t, err)
+
+	shas := make(map[string]string)
+	var hash plumbing.Hash
+	for _, tag := range tags {
+		assert.NoError(t, os.WriteFile(filepath.Join(workDir, "version.txt"), []byte(tag), 0644))
+		_, err = worktree.Add("version.txt")
+		assert.NoError(t, err)
+		hash, err = worktree.Commit(tag, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		assert.NoError(t, err)
+		_, err = repo.CreateTag(tag, hash, nil)
+		assert.NoError(t, err)
+		shas[tag] = hash.String()
+	}
+	assert.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/main", hash)))
+
+	// The cache holds bare repositories, which have the same layout as .git
+	repoDir := NewPackagesRepository().GetCacheDir() + `\repos\` + name
+	assert.NoError(t, os.MkdirAll(filepath.Dir(repoDir), os.ModePerm))
+	assert.NoError(t, os.Rename(filepath.Join(workDir, ".git"), repoDir))
+	return shas
+}
+
+func TestSelectCacheEvictions(t *testing.T) {
+	now := time.Date(2022, 10, 31, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time {
+		return now.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	cachedPackages := []CachedPackage{
+		{
+			Name:     "github.com/a/a",
+			Size:     300,
+			LastUsed: daysAgo(40),
+			Snapshots: []CachedSnapshot{
+				{SHA: "a1", Size: 100, LastUsed: daysAgo(40)},
+			},
+		},
+		{
+			Name:     "github.com/b/b",
+			Size:     500,
+			LastUsed: daysAgo(1),
+			Snapshots: []CachedSnapshot{
+				{SHA: "b1", Size: 100, LastUsed: daysAgo(50)},
+				{SHA: "b2", Size: 100, LastUsed: daysAgo(1)},
+				{SHA: "b3", Size: 100, LastUsed: daysAgo(5)},
+			},
+		},
+		{
+			Name:     "github.com/c/c",
+			Size:     200,
+			LastUsed: daysAgo(10),
+		},
+	}
+	protected := []ResolvedDependency{
+		{Name: "github.com/b/b", SHA: "b2"},
+	}
+
+	type Case struct {
+		name     string
+		limits   CacheLimits
+		expected []string
+	}
+	cases := []Case{
+		{"no limits", CacheLimits{}, []string{}},
+		{"max age", CacheLimits{MaxAge: 30 * 24 * time.Hour}, []string{"github.com/b/b@b1", "github.com/a/a"}},
+		{"max size", CacheLimits{MaxSize: 700}, []string{"github.com/b/b@b1", "github.com/a/a"}},
+		{"max size evicts recent packages", CacheLimits{MaxSize: 350}, []string{"github.com/b/b@b1", "github.com/a/a", "github.com/c/c", "github.com/b/b@b3"}},
+		{"protected packages are never evicted", CacheLimits{MaxSize: 1}, []string{"github.com/b/b@b1", "github.com/a/a", "github.com/c/c", "github.com/b/b@b3"}},
+		{"within limits", CacheLimits{MaxSize: 1000, MaxAge: 60 * 24 * time.Hour}, []string{}},
+	}
+
+	for _, c := range cases {
+		evictions := SelectCacheEvictions(cachedPackages, protected, c.limits, now)
+		names := make([]string, len(evictions))
+		for i, eviction := range evictions {
+			names[i] = eviction.Name
+			if eviction.SHA != "" {
+				names[i] += "@" + eviction.SHA
+			}
+		}
+		assert.Equal(t, c.expected, names, c.name)
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Prune removes package directories from ahkpm-modules which do not match any
+// install path in the lockfile, along with any host and owner directories
+// left empty. It returns the removed paths. If dryRun is true, nothing is
+// removed.
+func Prune(lm LockManifest, dryRun bool) ([]string, error) {
+	installedPaths, err := FindInstalledPackagePaths("ahkpm-modules")
+	if err != nil {
+		return nil, err
+	}
+
+	prunablePaths := GetPrunablePaths(lm.Resolved, installedPaths)
+	if dryRun {
+		return prunablePaths, nil
+	}
+
+	for _, prunablePath := range prunablePaths {
+		err := os.RemoveAll(prunablePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = removeEmptyModuleDirectories("ahkpm-modules")
+	if err != nil {
+		return nil, err
+	}
+
+	return prunablePaths, nil
+}
+
+// GetPrunablePaths returns the installed package paths which are not install
+// paths in the lockfile. Paths nested within another prunable path are
+// omitted, since removing the outer path removes them too.
+func GetPrunablePaths(resolved []ResolvedDependency, installedPaths []string) []string {
+	expectedPaths := make([]string, 0, len(resolved))
+	for _, resolvedDep := range resolved {
+		expectedPaths = append(expectedPaths, resolvedDep.InstallPath)
+	}
+
+	prunablePaths := make([]string, 0)
+	for _, installedPath := range installedPaths {
+		if slices.Contains(expectedPaths, installedPath) {
+			continue
+		}
+
+		isNested := false
+		for _, prunablePath := range prunablePaths {
+			if strings.HasPrefix(installedPath, prunablePath+"/") {
+				isNested = true
+				break
+			}
+		}
+		if !isNested {
+			prunablePaths = append(prunablePaths, installedPath)
+		}
+	}
+
+	return prunablePaths
+}
+
+// removeEmptyModuleDirectories removes empty host and owner directories from
+// the modules directory and from the modules directories of the packages
+// within it
+func removeEmptyModuleDirectories(modulesDir string) error {
+	hosts, err := readSubdirectories(modulesDir)
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		owners, err := readSubdirectories(host)
+		if err != nil {
+			return err
+		}
+		for _, owner := range owners {
+			repos, err := readSubdirectories(owner)
+			if err != nil {
+				return err
+			}
+			for _, repo := range repos {
+				err := removeEmptyModuleDirectories(repo + "/ahkpm-modules")
+				if err != nil {
+					return err
+				}
+			}
+			err = removeIfEmpty(owner)
+			if err != nil {
+				return err
+			}
+		}
+		err = removeIfEmpty(host)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeIfEmpty(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return os.Remove(dir)
+	}
+	return nil
+}
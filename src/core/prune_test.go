@@ -0,0 +1,29 @@
+package core_test
+
+import (
+	. "ahkpm/src/core"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPrunablePaths(t *testing.T) {
+	resolved := []ResolvedDependency{
+		{Name: "github.com/a/a", InstallPath: "ahkpm-modules/github.com/a/a"},
+		{Name: "github.com/c/c", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c"},
+	}
+	installedPaths := []string{
+		"ahkpm-modules/github.com/a/a",
+		"ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c",
+		"ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/e/e",
+		"ahkpm-modules/github.com/d/d",
+		"ahkpm-modules/github.com/d/d/ahkpm-modules/github.com/c/c",
+	}
+
+	prunablePaths := GetPrunablePaths(resolved, installedPaths)
+
+	assert.Equal(t, []string{
+		"ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/e/e",
+		"ahkpm-modules/github.com/d/d",
+	}, prunablePaths)
+}
@@ -1,11 +1,26 @@
 package core
 
 type ResolvedDependency struct {
-	Name         string        `json:"name"`
-	Version      string        `json:"version"`
-	SHA          string        `json:"sha"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Tag is the tag which satisfied Version, if any
+	Tag string `json:"tag,omitempty"`
+	SHA string `json:"sha"`
+	// Source is the URL the package was fetched from
+	Source string `json:"source,omitempty"`
+	// Integrity is a hash of the installed files, used to detect tampering
+	Integrity string `json:"integrity,omitempty"`
+	// ResolvedAt is when the dependency was resolved, in RFC 3339 format
+	ResolvedAt   string        `json:"resolvedAt,omitempty"`
 	InstallPath  string        `json:"installPath"`
 	Dependencies DependencySet `json:"dependencies"`
+	// RequiredBy lists the packages which led to this one, starting from a
+	// top-level dependency. It is only recorded when the install path does
+	// not already show them, as with the flat install layout.
+	RequiredBy []string `json:"requiredBy,omitempty"`
+	// Deduped is set once `ahkpm dedupe` has moved the package up so that
+	// every dependency on it shares a single copy
+	Deduped bool `json:"deduped,omitempty"`
 }
 
 func (rd ResolvedDependency) WithDependencies(deps DependencySet) ResolvedDependency {
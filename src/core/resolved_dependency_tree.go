@@ -43,6 +43,145 @@ func (r ResolvedDependencyTree) EnsureInstallPaths() ResolvedDependencyTree {
 	})
 }
 
+const (
+	// NestedInstallLayout installs each dependency within the ahkpm-modules
+	// directory of the package which requires it
+	NestedInstallLayout = "nested"
+	// FlatInstallLayout installs each dependency once, directly in the
+	// top-level ahkpm-modules directory
+	FlatInstallLayout = "flat"
+)
+
+// WithInstallLayout sets the install path of every dependency according to
+// the layout. An empty layout is the same as NestedInstallLayout.
+func (r ResolvedDependencyTree) WithInstallLayout(layout string) (ResolvedDependencyTree, error) {
+	switch layout {
+	case "", NestedInstallLayout:
+		return r.withNestedInstallPaths(), nil
+	case FlatInstallLayout:
+		return r.withFlatInstallPaths(), nil
+	}
+	return nil, fmt.Errorf("Invalid installLayout %q in ahkpm.json. Expected %q or %q.", layout, NestedInstallLayout, FlatInstallLayout)
+}
+
+// withNestedInstallPaths installs every dependency within the ahkpm-modules
+// directory of the package which requires it. Deduped packages are instead
+// installed once, in the ahkpm-modules directory of the closest package
+// which all of their copies have in common.
+func (r ResolvedDependencyTree) withNestedInstallPaths() ResolvedDependencyTree {
+	// Find the chain of names shared by the parents of every deduped copy
+	commonChains := make(map[string][]string)
+	var findCommonChains func(nodes ResolvedDependencyTree, parentChain []string)
+	findCommonChains = func(nodes ResolvedDependencyTree, parentChain []string) {
+		for _, node := range nodes {
+			if node.Value.Deduped {
+				key := node.Value.Name + " " + node.Value.SHA
+				if common, ok := commonChains[key]; ok {
+					commonChains[key] = getCommonPrefix(common, parentChain)
+				} else {
+					commonChains[key] = parentChain
+				}
+			}
+			chain := append(parentChain[:len(parentChain):len(parentChain)], node.Value.Name)
+			findCommonChains(node.Children, chain)
+		}
+	}
+	findCommonChains(r, []string{})
+
+	return r.placeDependencies(func(dep ResolvedDependency, pathsByChain map[string]string) string {
+		if !dep.Deduped {
+			return ""
+		}
+		commonChain := commonChains[dep.Name+" "+dep.SHA]
+		if len(commonChain) == 0 {
+			return "ahkpm-modules/" + dep.Name
+		}
+		return pathsByChain[strings.Join(commonChain, " ")] + "/ahkpm-modules/" + dep.Name
+	})
+}
+
+// withFlatInstallPaths installs every dependency at ahkpm-modules/<name>. The
+// resolver selects only one commit of each package, so they never collide.
+func (r ResolvedDependencyTree) withFlatInstallPaths() ResolvedDependencyTree {
+	return r.placeDependencies(func(dep ResolvedDependency, pathsByChain map[string]string) string {
+		return "ahkpm-modules/" + dep.Name
+	})
+}
+
+// placeDependencies sets the install path of every dependency. sharedPath
+// returns the path at which a dependency should share a single copy with other
+// dependencies on the same package, or an empty string to nest it within the
+// package which requires it. When an install path no longer shows which
+// packages led to a dependency, they are recorded in RequiredBy.
+func (r ResolvedDependencyTree) placeDependencies(
+	sharedPath func(dep ResolvedDependency, pathsByChain map[string]string) string,
+) ResolvedDependencyTree {
+	type queueItem struct {
+		node       TreeNode[ResolvedDependency]
+		chain      []string
+		parentPath string
+	}
+
+	// Decide on paths breadth-first so that the paths of the packages which a
+	// shared copy can be placed within are known before it is placed
+	pathsByChain := make(map[string]string)
+	queue := make([]queueItem, 0, len(r))
+	for _, node := range r {
+		queue = append(queue, queueItem{node: node, chain: []string{node.Value.Name}})
+	}
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+
+		path := "ahkpm-modules/" + item.node.Value.Name
+		if item.parentPath != "" {
+			path = item.parentPath + "/ahkpm-modules/" + item.node.Value.Name
+		}
+		if shared := sharedPath(item.node.Value, pathsByChain); shared != "" {
+			path = shared
+		}
+		pathsByChain[strings.Join(item.chain, " ")] = path
+
+		for _, child := range item.node.Children {
+			queue = append(queue, queueItem{
+				node:       child,
+				chain:      append(item.chain[:len(item.chain):len(item.chain)], child.Value.Name),
+				parentPath: path,
+			})
+		}
+	}
+
+	return setInstallPathsByChain(r, []string{}, pathsByChain)
+}
+
+func setInstallPathsByChain(
+	nodes ResolvedDependencyTree,
+	requiredBy []string,
+	pathsByChain map[string]string,
+) ResolvedDependencyTree {
+	result := make(ResolvedDependencyTree, len(nodes))
+	for i, node := range nodes {
+		chain := append(requiredBy[:len(requiredBy):len(requiredBy)], node.Value.Name)
+		node.Value.InstallPath = pathsByChain[strings.Join(chain, " ")]
+		node.Value.RequiredBy = nil
+		if !slices.Equal(getDependerNamesFromInstallPath(node.Value), requiredBy) {
+			node.Value.RequiredBy = slices.Clone(requiredBy)
+		}
+		node.Children = setInstallPathsByChain(node.Children, chain, pathsByChain)
+		result[i] = node
+	}
+	return result
+}
+
+// getCommonPrefix returns the names at the start of both a and b
+func getCommonPrefix(a []string, b []string) []string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
 // Merge merges two resolved dependency trees from right to left, by replacing
 // the left tree's root nodes with the right tree's root nodes if they have the
 // same name. Any root nodes in the right tree that do not exist in the left
@@ -77,6 +216,42 @@ func (r ResolvedDependencyTree) RemoveTopLevelDependencies(depNames []string) Re
 	return newTree
 }
 
+// FilterByName returns only the parts of the tree which lead to a dependency
+// with the given name. Matching dependencies keep all of their children.
+func (r ResolvedDependencyTree) FilterByName(name string) ResolvedDependencyTree {
+	newTree := make(ResolvedDependencyTree, 0)
+	for _, depNode := range r {
+		if depNode.Value.Name == name {
+			newTree = append(newTree, depNode)
+			continue
+		}
+
+		children := ResolvedDependencyTree(depNode.Children).FilterByName(name)
+		if len(children) > 0 {
+			depNode.Children = children
+			newTree = append(newTree, depNode)
+		}
+	}
+	return newTree
+}
+
+// PathsTo returns every path from a top-level dependency to a dependency with
+// the given name. Each path starts with the top-level dependency and ends with
+// the named dependency.
+func (r ResolvedDependencyTree) PathsTo(name string) [][]ResolvedDependency {
+	paths := make([][]ResolvedDependency, 0)
+	for _, depNode := range r {
+		if depNode.Value.Name == name {
+			paths = append(paths, []ResolvedDependency{depNode.Value})
+		}
+
+		for _, childPath := range ResolvedDependencyTree(depNode.Children).PathsTo(name) {
+			paths = append(paths, append([]ResolvedDependency{depNode.Value}, childPath...))
+		}
+	}
+	return paths
+}
+
 func getRelativeInstallPath(n TreeNode[ResolvedDependency]) string {
 	path := n.Value.Name
 	parent := n.Parent
@@ -98,19 +273,22 @@ func ResolvedDependencyTreeFromArray(arr []ResolvedDependency) ResolvedDependenc
 		dependerNames []string
 	}
 
-	// Derive depender names from the install path. The names will be used to build the tree
+	// Derive depender names from the install path, unless they were recorded
+	// because the install path doesn't reflect them. The names will be used to
+	// build the tree
 	tempResults := make([]intermediateResult, len(arr))
 	for i, dep := range arr {
-		pathWithoutSelf := strings.TrimSuffix(dep.InstallPath, "ahkpm-modules/"+dep.Name)
-		pathWithoutPrefix := strings.TrimPrefix(pathWithoutSelf, "ahkpm-modules/")
-		pathWithoutEndingSlash := strings.TrimSuffix(pathWithoutPrefix, "/")
-		dependerNames := strings.Split(pathWithoutEndingSlash, "/ahkpm-modules/")
-		if len(dependerNames) == 1 && dependerNames[0] == "" {
-			dependerNames = []string{}
+		if len(dep.RequiredBy) > 0 {
+			tempResults[i] = intermediateResult{
+				dep:           dep,
+				dependerNames: dep.RequiredBy,
+			}
+			continue
 		}
+
 		tempResults[i] = intermediateResult{
 			dep:           dep,
-			dependerNames: dependerNames,
+			dependerNames: getDependerNamesFromInstallPath(dep),
 		}
 	}
 
@@ -147,6 +325,19 @@ func ResolvedDependencyTreeFromArray(arr []ResolvedDependency) ResolvedDependenc
 	return tree
 }
 
+// getDependerNamesFromInstallPath returns the names of the packages which
+// the install path of the dependency is nested within
+func getDependerNamesFromInstallPath(dep ResolvedDependency) []string {
+	pathWithoutSelf := strings.TrimSuffix(dep.InstallPath, "ahkpm-modules/"+dep.Name)
+	pathWithoutPrefix := strings.TrimPrefix(pathWithoutSelf, "ahkpm-modules/")
+	pathWithoutEndingSlash := strings.TrimSuffix(pathWithoutPrefix, "/")
+	dependerNames := strings.Split(pathWithoutEndingSlash, "/ahkpm-modules/")
+	if len(dependerNames) == 1 && dependerNames[0] == "" {
+		dependerNames = []string{}
+	}
+	return dependerNames
+}
+
 func remove[T any](slice []T, s int) []T {
 	return append(slice[:s], slice[s+1:]...)
 }
@@ -196,18 +387,22 @@ func SortAllByNames(t *[]TreeNode[ResolvedDependency]) {
 	}
 }
 
+// CheckForConflicts returns an error if any dependency occurs more than once in
+// the tree and was resolved to different commits. Differing version strings are
+// allowed as long as they resolved to the same commit, as happens when
+// overlapping ranges are unified by the resolver.
 func (depNodes ResolvedDependencyTree) CheckForConflicts() error {
 	allDeps := depNodes.Flatten()
 
 	depMap := make(map[string]ResolvedDependency)
 	for _, dep := range allDeps {
-		// If the dependency is already in the map, check if the versions are the same.
+		// If the dependency is already in the map, check if the SHAs are the same.
 		if existingDep, ok := depMap[dep.Name]; ok {
-			if existingDep.Version != dep.Version {
-				return fmt.Errorf("Conflicting versions for dependency %s: %s and %s", dep.Name, existingDep.Version, dep.Version)
-			}
 			if existingDep.SHA != dep.SHA {
-				return fmt.Errorf("Conflicting SHAs for dependency %s: %s and %s", dep.Name, existingDep.SHA, dep.SHA)
+				return fmt.Errorf(
+					"Conflicting versions for dependency %s: %s (%s) and %s (%s)",
+					dep.Name, existingDep.Version, existingDep.SHA, dep.Version, dep.SHA,
+				)
 			}
 		} else {
 			depMap[dep.Name] = dep
@@ -257,3 +257,125 @@ func TestRemoveTopLevelDependenciesByName(t *testing.T) {
 	actual := tree.RemoveTopLevelDependencies([]string{"github.com/a/a"})
 	assert.Equal(t, expected, actual)
 }
+
+func TestFilterByName(t *testing.T) {
+	tree := ResolvedDependencyTreeFromArray([]ResolvedDependency{
+		{
+			Name:        "github.com/a/a",
+			InstallPath: "ahkpm-modules/github.com/a/a",
+		},
+		{
+			Name:        "github.com/aa/aa",
+			InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/aa/aa",
+		},
+		{
+			Name:        "github.com/ab/ab",
+			InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/ab/ab",
+		},
+		{
+			Name:        "github.com/aba/aba",
+			InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/ab/ab/ahkpm-modules/github.com/aba/aba",
+		},
+		{
+			Name:        "github.com/b/b",
+			InstallPath: "ahkpm-modules/github.com/b/b",
+		},
+	})
+
+	filtered := tree.FilterByName("github.com/ab/ab")
+
+	assert.Equal(t, 1, len(filtered))
+	assert.Equal(t, "github.com/a/a", filtered[0].Value.Name)
+	assert.Equal(t, 1, len(filtered[0].Children))
+	assert.Equal(t, "github.com/ab/ab", filtered[0].Children[0].Value.Name)
+	assert.Equal(t, "github.com/aba/aba", filtered[0].Children[0].Children[0].Value.Name)
+}
+
+func TestPathsTo(t *testing.T) {
+	tree := ResolvedDependencyTreeFromArray([]ResolvedDependency{
+		{
+			Name:        "github.com/a/a",
+			InstallPath: "ahkpm-modules/github.com/a/a",
+		},
+		{
+			Name:        "github.com/c/c",
+			InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c",
+		},
+		{
+			Name:        "github.com/b/b",
+			InstallPath: "ahkpm-modules/github.com/b/b",
+		},
+		{
+			Name:        "github.com/ba/ba",
+			InstallPath: "ahkpm-modules/github.com/b/b/ahkpm-modules/github.com/ba/ba",
+		},
+		{
+			Name:        "github.com/c/c",
+			InstallPath: "ahkpm-modules/github.com/b/b/ahkpm-modules/github.com/ba/ba/ahkpm-modules/github.com/c/c",
+		},
+	})
+
+	paths := tree.PathsTo("github.com/c/c")
+
+	assert.Equal(t, 2, len(paths))
+	assert.Equal(t, "github.com/a/a", paths[0][0].Name)
+	assert.Equal(t, "github.com/c/c", paths[0][1].Name)
+	assert.Equal(t, "github.com/b/b", paths[1][0].Name)
+	assert.Equal(t, "github.com/ba/ba", paths[1][1].Name)
+	assert.Equal(t, "github.com/c/c", paths[1][2].Name)
+	assert.Equal(t, 0, len(tree.PathsTo("github.com/d/d")))
+}
+
+func TestWithFlatInstallLayout(t *testing.T) {
+	// a and b both need c and d
+	r := ResolvedDependencyTree{
+		{
+			Value: ResolvedDependency{Name: "github.com/a/a", SHA: "a1"},
+			Children: []TreeNode[ResolvedDependency]{
+				{Value: ResolvedDependency{Name: "github.com/c/c", SHA: "c1"}},
+				{Value: ResolvedDependency{Name: "github.com/d/d", SHA: "d1"}},
+			},
+		},
+		{
+			Value: ResolvedDependency{Name: "github.com/b/b", SHA: "b1"},
+			Children: []TreeNode[ResolvedDependency]{
+				{Value: ResolvedDependency{Name: "github.com/c/c", SHA: "c1"}},
+				{Value: ResolvedDependency{Name: "github.com/d/d", SHA: "d1"}},
+			},
+		},
+	}
+
+	flat, err := r.WithInstallLayout(FlatInstallLayout)
+	assert.Nil(t, err)
+
+	actual := make([]string, 0)
+	for _, dep := range flat.Flatten() {
+		actual = append(actual, strings.Join(dep.RequiredBy, ",")+" "+dep.Name+" "+dep.InstallPath)
+	}
+	expected := []string{
+		" github.com/a/a ahkpm-modules/github.com/a/a",
+		"github.com/a/a github.com/c/c ahkpm-modules/github.com/c/c",
+		"github.com/a/a github.com/d/d ahkpm-modules/github.com/d/d",
+		" github.com/b/b ahkpm-modules/github.com/b/b",
+		"github.com/b/b github.com/c/c ahkpm-modules/github.com/c/c",
+		"github.com/b/b github.com/d/d ahkpm-modules/github.com/d/d",
+	}
+	assert.Equal(t, expected, actual)
+
+	// The tree can be rebuilt from the lockfile even though the install paths
+	// no longer show which package required each dependency
+	rebuilt := ResolvedDependencyTreeFromArray(flat.Flatten())
+	assert.Equal(t, flat.Flatten(), rebuilt.Flatten())
+
+	nested, err := rebuilt.WithInstallLayout(NestedInstallLayout)
+	assert.Nil(t, err)
+	for _, dep := range nested.Flatten() {
+		assert.Nil(t, dep.RequiredBy)
+	}
+	assert.Equal(t, "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/d/d", nested[0].Children[1].Value.InstallPath)
+}
+
+func TestWithInvalidInstallLayout(t *testing.T) {
+	_, err := ResolvedDependencyTree{}.WithInstallLayout("hoisted")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"ahkpm/src/utils"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+type VerificationIssueKind string
+
+const (
+	// MissingPackage is a package in the lockfile which is not installed
+	MissingPackage VerificationIssueKind = "missing"
+	// ExtraneousPackage is an installed package which is not in the lockfile
+	ExtraneousPackage VerificationIssueKind = "extraneous"
+	// ModifiedPackage is an installed package whose files have been changed
+	ModifiedPackage VerificationIssueKind = "modified"
+	// MisplacedPackage is a package in the lockfile which is installed at a
+	// path the lockfile does not expect
+	MisplacedPackage VerificationIssueKind = "misplaced"
+)
+
+// VerificationIssue is a difference between ahkpm-modules and the lockfile
+type VerificationIssue struct {
+	Kind VerificationIssueKind `json:"kind"`
+	Name string                `json:"name"`
+	Path string                `json:"path"`
+	// ExpectedPaths lists where the lockfile expects a misplaced package
+	ExpectedPaths []string `json:"expectedPaths,omitempty"`
+}
+
+func (issue VerificationIssue) String() string {
+	switch issue.Kind {
+	case MissingPackage:
+		return issue.Name + " is missing from " + issue.Path
+	case ExtraneousPackage:
+		return issue.Path + " is not in ahkpm.lock"
+	case ModifiedPackage:
+		return issue.Name + " has been modified at " + issue.Path
+	case MisplacedPackage:
+		return issue.Name + " is installed at " + issue.Path +
+			", but ahkpm.lock expects it at " + strings.Join(issue.ExpectedPaths, " or ")
+	}
+	return string(issue.Kind) + ": " + issue.Path
+}
+
+// VerifyInstallation compares the packages installed in ahkpm-modules with
+// the lockfile
+func VerifyInstallation(pr PackagesRepository, lm LockManifest) ([]VerificationIssue, error) {
+	installedPaths, err := FindInstalledPackagePaths("ahkpm-modules")
+	if err != nil {
+		return nil, err
+	}
+
+	issues := FindInstallationIssues(lm.Resolved, installedPaths)
+
+	for _, resolvedDep := range uniqueByInstallPath(lm.Resolved) {
+		if !slices.Contains(installedPaths, resolvedDep.InstallPath) {
+			continue
+		}
+
+		isModified, err := isPackageModified(pr, resolvedDep)
+		if err != nil {
+			return nil, err
+		}
+		if isModified {
+			issues = append(issues, VerificationIssue{
+				Kind: ModifiedPackage,
+				Name: resolvedDep.Name,
+				Path: resolvedDep.InstallPath,
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// isPackageModified compares the hash of the installed files with the one in
+// the lockfile, or with the cached package at the locked SHA if the lockfile
+// has no hash
+func isPackageModified(pr PackagesRepository, resolvedDep ResolvedDependency) (bool, error) {
+	expected := resolvedDep.Integrity
+	if expected == "" {
+		var err error
+		expected, err = pr.GetPackageIntegrity(resolvedDep)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	actual, err := utils.HashDirectory(resolvedDep.InstallPath, ".git", "ahkpm-modules")
+	if err != nil {
+		return false, err
+	}
+
+	return actual != expected, nil
+}
+
+// FindInstallationIssues compares the install paths in the lockfile with the
+// package directories found on disk, reporting missing, extraneous and
+// misplaced packages
+func FindInstallationIssues(resolved []ResolvedDependency, installedPaths []string) []VerificationIssue {
+	issues := make([]VerificationIssue, 0)
+	resolved = uniqueByInstallPath(resolved)
+
+	expectedPathsByName := make(map[string][]string)
+	expectedPaths := make([]string, 0, len(resolved))
+	for _, resolvedDep := range resolved {
+		expectedPathsByName[resolvedDep.Name] = append(expectedPathsByName[resolvedDep.Name], resolvedDep.InstallPath)
+		expectedPaths = append(expectedPaths, resolvedDep.InstallPath)
+	}
+
+	for _, resolvedDep := range resolved {
+		if !slices.Contains(installedPaths, resolvedDep.InstallPath) {
+			issues = append(issues, VerificationIssue{
+				Kind: MissingPackage,
+				Name: resolvedDep.Name,
+				Path: resolvedDep.InstallPath,
+			})
+		}
+	}
+
+	for _, installedPath := range installedPaths {
+		if slices.Contains(expectedPaths, installedPath) {
+			continue
+		}
+
+		name := getPackageNameFromInstallPath(installedPath)
+		if paths, ok := expectedPathsByName[name]; ok {
+			issues = append(issues, VerificationIssue{
+				Kind:          MisplacedPackage,
+				Name:          name,
+				Path:          installedPath,
+				ExpectedPaths: paths,
+			})
+		} else {
+			issues = append(issues, VerificationIssue{
+				Kind: ExtraneousPackage,
+				Name: name,
+				Path: installedPath,
+			})
+		}
+	}
+
+	return issues
+}
+
+// FindInstalledPackagePaths returns the path of every package directory in
+// the modules directory, including packages nested in the ahkpm-modules
+// directories of other packages. Paths use forward slashes to match the
+// install paths in the lockfile.
+func FindInstalledPackagePaths(modulesDir string) ([]string, error) {
+	paths := make([]string, 0)
+
+	// Package directories are always three levels deep: host/owner/repo
+	hosts, err := readSubdirectories(modulesDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		owners, err := readSubdirectories(host)
+		if err != nil {
+			return nil, err
+		}
+		for _, owner := range owners {
+			repos, err := readSubdirectories(owner)
+			if err != nil {
+				return nil, err
+			}
+			for _, repo := range repos {
+				paths = append(paths, repo)
+
+				nestedPaths, err := FindInstalledPackagePaths(repo + "/ahkpm-modules")
+				if err != nil {
+					return nil, err
+				}
+				paths = append(paths, nestedPaths...)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// readSubdirectories returns the paths of the directories within dir, or
+// nothing if dir does not exist
+func readSubdirectories(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	subdirectories := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			subdirectories = append(subdirectories, path.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(subdirectories)
+	return subdirectories, nil
+}
+
+// getPackageNameFromInstallPath returns the package name from the last three
+// segments of an install path
+func getPackageNameFromInstallPath(installPath string) string {
+	segments := strings.Split(installPath, "/")
+	if len(segments) < 3 {
+		return installPath
+	}
+	return strings.Join(segments[len(segments)-3:], "/")
+}
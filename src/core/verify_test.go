@@ -0,0 +1,74 @@
+package core_test
+
+import (
+	. "ahkpm/src/core"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindInstallationIssues(t *testing.T) {
+	resolved := []ResolvedDependency{
+		{Name: "github.com/a/a", InstallPath: "ahkpm-modules/github.com/a/a"},
+		{Name: "github.com/b/b", InstallPath: "ahkpm-modules/github.com/b/b"},
+		{Name: "github.com/c/c", InstallPath: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c"},
+	}
+	installedPaths := []string{
+		"ahkpm-modules/github.com/a/a",
+		"ahkpm-modules/github.com/c/c",
+		"ahkpm-modules/github.com/d/d",
+	}
+
+	issues := FindInstallationIssues(resolved, installedPaths)
+
+	assert.Equal(t, []VerificationIssue{
+		{Kind: MissingPackage, Name: "github.com/b/b", Path: "ahkpm-modules/github.com/b/b"},
+		{Kind: MissingPackage, Name: "github.com/c/c", Path: "ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c"},
+		{
+			Kind:          MisplacedPackage,
+			Name:          "github.com/c/c",
+			Path:          "ahkpm-modules/github.com/c/c",
+			ExpectedPaths: []string{"ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c"},
+		},
+		{Kind: ExtraneousPackage, Name: "github.com/d/d", Path: "ahkpm-modules/github.com/d/d"},
+	}, issues)
+}
+
+func TestFindInstalledPackagePaths(t *testing.T) {
+	modulesDir := filepath.ToSlash(filepath.Join(t.TempDir(), "ahkpm-modules"))
+	assert.NoError(t, os.MkdirAll(modulesDir+"/github.com/a/a/ahkpm-modules/github.com/c/c", 0755))
+	assert.NoError(t, os.MkdirAll(modulesDir+"/github.com/b/b", 0755))
+
+	paths, err := FindInstalledPackagePaths(modulesDir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		modulesDir + "/github.com/a/a",
+		modulesDir + "/github.com/a/a/ahkpm-modules/github.com/c/c",
+		modulesDir + "/github.com/b/b",
+	}, paths)
+}
+
+func TestFindInstalledPackagePathsWithoutModulesDir(t *testing.T) {
+	paths, err := FindInstalledPackagePaths(filepath.Join(t.TempDir(), "ahkpm-modules"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, paths)
+}
+
+func TestVerificationIssueString(t *testing.T) {
+	issue := VerificationIssue{
+		Kind:          MisplacedPackage,
+		Name:          "github.com/c/c",
+		Path:          "ahkpm-modules/github.com/c/c",
+		ExpectedPaths: []string{"ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c"},
+	}
+
+	assert.Equal(
+		t,
+		"github.com/c/c is installed at ahkpm-modules/github.com/c/c, but ahkpm.lock expects it at ahkpm-modules/github.com/a/a/ahkpm-modules/github.com/c/c",
+		issue.String(),
+	)
+}
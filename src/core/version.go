@@ -102,3 +102,58 @@ func (v version) Value() string {
 func (v version) Equals(other Version) bool {
 	return v.kind == other.Kind() && v.value == other.Value()
 }
+
+var (
+	caretOrTildeRangeRegex = regexp.MustCompile(`^([\^~])\s*v?\d+(\.\d+)?(\.\d+)?(-[0-9A-Za-z.-]+)?$`)
+	wildcardRangeRegex     = regexp.MustCompile(`^\d+(\.(\d+|[xX*]))?(\.(\d+|[xX*]))?$`)
+	majorVersionRegex      = regexp.MustCompile(`\d+`)
+)
+
+// ToLatestVersion returns a version which allows the latest version while
+// keeping the form of the original. Caret and tilde ranges keep their
+// operator, and wildcard ranges such as "1.x.x" keep their wildcards. Other
+// ranges are kept if they already allow the latest version, or replaced with
+// a caret range if they do not. Branches, tags and commits are not changed.
+func ToLatestVersion(v Version, latest string) Version {
+	switch v.Kind() {
+	case SemVerExact:
+		return NewVersion(SemVerExact, latest)
+	case SemVerRange:
+		value := v.Value()
+		if matches := caretOrTildeRangeRegex.FindStringSubmatch(value); matches != nil {
+			return NewVersion(SemVerRange, matches[1]+latest)
+		}
+		if wildcardRangeRegex.MatchString(value) && strings.ContainsAny(value, "xX*") {
+			latestParts := strings.Split(strings.SplitN(latest, "-", 2)[0], ".")
+			parts := strings.Split(value, ".")
+			for i, part := range parts {
+				if part != "x" && part != "X" && part != "*" && i < len(latestParts) {
+					parts[i] = latestParts[i]
+				}
+			}
+			return NewVersion(SemVerRange, strings.Join(parts, "."))
+		}
+		if satisfies(NewVersion(SemVerExact, latest), v) {
+			return v
+		}
+		return NewVersion(SemVerRange, "^"+latest)
+	default:
+		return v
+	}
+}
+
+// VersionChange describes a change to the version of a dependency in
+// ahkpm.json
+type VersionChange struct {
+	Name string
+	From Version
+	To   Version
+}
+
+// IsMajor returns true if the change moves the dependency to a different major
+// version
+func (c VersionChange) IsMajor() bool {
+	from := majorVersionRegex.FindString(c.From.Value())
+	to := majorVersionRegex.FindString(c.To.Value())
+	return from != "" && to != "" && from != to
+}
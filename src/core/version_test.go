@@ -77,3 +77,40 @@ func TestVersionEquals(t *testing.T) {
 		assert.Equal(t, c.expected, c.a.Equals(c.b))
 	}
 }
+
+func TestToLatestVersion(t *testing.T) {
+	type Case struct {
+		version  Version
+		latest   string
+		expected Version
+	}
+
+	cases := []Case{
+		{NewVersion(SemVerExact, "1.2.3"), "2.0.0", NewVersion(SemVerExact, "2.0.0")},
+		{NewVersion(SemVerRange, "^1.2.3"), "2.0.0", NewVersion(SemVerRange, "^2.0.0")},
+		{NewVersion(SemVerRange, "~1.2.3"), "1.4.0", NewVersion(SemVerRange, "~1.4.0")},
+		{NewVersion(SemVerRange, "1.x.x"), "3.1.4", NewVersion(SemVerRange, "3.x.x")},
+		{NewVersion(SemVerRange, "1.2.x"), "3.1.4", NewVersion(SemVerRange, "3.1.x")},
+		{NewVersion(SemVerRange, ">= 1.0.0"), "3.1.4", NewVersion(SemVerRange, ">= 1.0.0")},
+		{NewVersion(SemVerRange, ">= 1.0.0, < 2.0.0"), "3.1.4", NewVersion(SemVerRange, "^3.1.4")},
+		{NewVersion(Branch, "main"), "3.1.4", NewVersion(Branch, "main")},
+		{NewVersion(Tag, "v1"), "3.1.4", NewVersion(Tag, "v1")},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, ToLatestVersion(c.version, c.latest), c.version.String())
+	}
+}
+
+func TestVersionChangeIsMajor(t *testing.T) {
+	assert.True(t, VersionChange{
+		Name: "github.com/a/a",
+		From: NewVersion(SemVerRange, "^1.2.3"),
+		To:   NewVersion(SemVerRange, "^2.0.0"),
+	}.IsMajor())
+	assert.False(t, VersionChange{
+		Name: "github.com/a/a",
+		From: NewVersion(SemVerRange, "~1.2.3"),
+		To:   NewVersion(SemVerRange, "~1.4.0"),
+	}.IsMajor())
+}
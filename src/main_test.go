@@ -31,7 +31,9 @@ func TestInstallingNewPackageWithDependency(t *testing.T) {
 		{
 			Name:        "github.com/joshuacc/mock-ahkpm-package-a",
 			Version:     "^1.3.2",
+			Tag:         "1.3.2",
 			SHA:         "a7ecc280bf13fd81a4b28fef373b4e2b311f265f",
+			Source:      "https://github.com/joshuacc/mock-ahkpm-package-a.git",
 			InstallPath: "ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-a",
 			Dependencies: core.NewDependencySet().
 				AddDependency(
@@ -43,13 +45,14 @@ func TestInstallingNewPackageWithDependency(t *testing.T) {
 			Name:         "github.com/joshuacc/mock-ahkpm-package-b",
 			Version:      "branch:main",
 			SHA:          "c4ada0b84f91a7e673fc4bd687e805154adb67d5",
+			Source:       "https://github.com/joshuacc/mock-ahkpm-package-b.git",
 			InstallPath:  "ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-a/ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-b",
 			Dependencies: core.NewDependencySet(),
 		},
 	}
 
 	// Ensure that ahkpm.lock has the correct list of resolved dependencies, including transitive dependencies
-	assert.Equal(t, expectedResolved, lm.Resolved)
+	assert.Equal(t, expectedResolved, withoutInstallMetadata(t, lm.Resolved))
 
 	// Ensure that the correct files were installed
 	assert.FileExists(t, "ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-a/README.md")
@@ -137,7 +140,9 @@ func TestUpdating(t *testing.T) {
 		{
 			Name:        "github.com/joshuacc/mock-ahkpm-package-a",
 			Version:     "1.3.1",
+			Tag:         "1.3.1",
 			SHA:         "9d750cfbcffa05b8a33590a4a66c8047fd057452",
+			Source:      "https://github.com/joshuacc/mock-ahkpm-package-a.git",
 			InstallPath: "ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-a",
 			Dependencies: core.NewDependencySet().
 				AddDependency(
@@ -148,13 +153,14 @@ func TestUpdating(t *testing.T) {
 			Name:         "github.com/joshuacc/mock-ahkpm-package-b",
 			Version:      "branch:main",
 			SHA:          "c4ada0b84f91a7e673fc4bd687e805154adb67d5",
+			Source:       "https://github.com/joshuacc/mock-ahkpm-package-b.git",
 			InstallPath:  "ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-a/ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-b",
 			Dependencies: core.NewDependencySet(),
 		},
 	}
 
 	// Ensure that ahkpm.lock has the correct list of resolved dependencies, including transitive dependencies
-	assert.Equal(t, expectedResolved, lm.Resolved)
+	assert.Equal(t, expectedResolved, withoutInstallMetadata(t, lm.Resolved))
 
 	err = os.RemoveAll("ahkpm-modules")
 	assert.Nil(t, err)
@@ -179,7 +185,9 @@ func TestUpdating(t *testing.T) {
 		{
 			Name:        "github.com/joshuacc/mock-ahkpm-package-a",
 			Version:     "^1.3.1",
+			Tag:         "1.3.2",
 			SHA:         "a7ecc280bf13fd81a4b28fef373b4e2b311f265f", // 1.3.2
+			Source:      "https://github.com/joshuacc/mock-ahkpm-package-a.git",
 			InstallPath: "ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-a",
 			Dependencies: core.NewDependencySet().
 				AddDependency(
@@ -190,13 +198,14 @@ func TestUpdating(t *testing.T) {
 			Name:         "github.com/joshuacc/mock-ahkpm-package-b",
 			Version:      "branch:main",
 			SHA:          "c4ada0b84f91a7e673fc4bd687e805154adb67d5",
+			Source:       "https://github.com/joshuacc/mock-ahkpm-package-b.git",
 			InstallPath:  "ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-a/ahkpm-modules/github.com/joshuacc/mock-ahkpm-package-b",
 			Dependencies: core.NewDependencySet(),
 		},
 	}
 
 	// Ensure that ahkpm.lock has the correct list of resolved dependencies, including transitive dependencies
-	assert.Equal(t, expectedResolved, lm.Resolved)
+	assert.Equal(t, expectedResolved, withoutInstallMetadata(t, lm.Resolved))
 
 	cleanupFiles(t)
 }
@@ -246,3 +255,17 @@ func cleanupFiles(t *testing.T) {
 	err = os.Remove("ahkpm.lock")
 	assert.Nil(t, err)
 }
+
+// withoutInstallMetadata checks that the integrity hash and resolution time
+// were recorded, then clears them since they vary between runs
+func withoutInstallMetadata(t *testing.T, resolved []core.ResolvedDependency) []core.ResolvedDependency {
+	cleared := make([]core.ResolvedDependency, len(resolved))
+	for i, dep := range resolved {
+		assert.NotEmpty(t, dep.Integrity)
+		assert.NotEmpty(t, dep.ResolvedAt)
+		dep.Integrity = ""
+		dep.ResolvedAt = ""
+		cleared[i] = dep
+	}
+	return cleared
+}
@@ -16,6 +16,11 @@ func (m *MockPackagesRepository) CopyPackage(dep ResolvedDependency, path string
 	return args.Error(1)
 }
 
+func (m *MockPackagesRepository) ReextractPackage(dep ResolvedDependency) error {
+	args := m.Called(dep)
+	return args.Error(0)
+}
+
 func (m *MockPackagesRepository) GetPackageDependencies(dep ResolvedDependency) (*DependencySet, error) {
 	args := m.Called(dep)
 	return args.Get(0).(*DependencySet), args.Error(1)
@@ -31,12 +36,63 @@ func (m *MockPackagesRepository) ClearCache() error {
 	return args.Error(0)
 }
 
+func (m *MockPackagesRepository) GetCacheDir() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockPackagesRepository) ListCache() ([]CachedPackage, error) {
+	args := m.Called()
+	return args.Get(0).([]CachedPackage), args.Error(1)
+}
+
+func (m *MockPackagesRepository) AddToCache(dep Dependency) (string, error) {
+	args := m.Called(dep)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPackagesRepository) RemoveFromCache(depName string) error {
+	args := m.Called(depName)
+	return args.Error(0)
+}
+
+func (m *MockPackagesRepository) VerifyCache() ([]CacheIssue, error) {
+	args := m.Called()
+	return args.Get(0).([]CacheIssue), args.Error(1)
+}
+
+func (m *MockPackagesRepository) CollectCacheGarbage(limits CacheLimits, protected []ResolvedDependency) ([]CacheEviction, error) {
+	args := m.Called(limits, protected)
+	return args.Get(0).([]CacheEviction), args.Error(1)
+}
+
 func (m *MockPackagesRepository) WithRemoveAll(removeAll func(path string) error) PackagesRepository {
 	m.On("WithRemoveAll", removeAll).Return(m)
 	return m
 }
 
+func (m *MockPackagesRepository) WithInstallMode(installMode string) PackagesRepository {
+	m.On("WithInstallMode", installMode).Return(m)
+	return m
+}
+
 func (m *MockPackagesRepository) GetLatestVersion(depName string) (Version, error) {
 	args := m.Called(depName)
-	return args.Get(0).(Version), args.Error(1)
+	version, _ := args.Get(0).(Version)
+	return version, args.Error(1)
+}
+
+func (m *MockPackagesRepository) GetVersions(depName string) ([]string, error) {
+	args := m.Called(depName)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockPackagesRepository) GetVersionForSHA(depName string, sha string) (string, error) {
+	args := m.Called(depName, sha)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPackagesRepository) GetPackageIntegrity(dep ResolvedDependency) (string, error) {
+	args := m.Called(dep)
+	return args.String(0), args.Error(1)
 }
@@ -1,14 +1,21 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"golang.org/x/exp/slices"
 )
 
 func IsSemVer(value string) bool {
@@ -51,12 +58,22 @@ func StructFromFile[T any](path string, s *T) (*T, error) {
 }
 
 func GetAhkpmDir() string {
+	dir, err := LookupAhkpmDir()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return dir
+}
+
+// LookupAhkpmDir returns the ahkpm directory in the user's profile, or an
+// error if the location of the profile is unknown
+func LookupAhkpmDir() (string, error) {
 	value, succeeded := os.LookupEnv("userprofile")
 	if !succeeded {
-		fmt.Println("Unable to get userprofile")
-		os.Exit(1)
+		return "", errors.New("Unable to get userprofile")
 	}
-	return value + `\.ahkpm`
+	return value + `\.ahkpm`, nil
 }
 
 func GetAutoHotkeyVersion() (string, error) {
@@ -76,9 +93,201 @@ func GetAutoHotkeyVersion() (string, error) {
 	return string(out), nil
 }
 
+// WriteFileAtomic writes data to a temporary file in the same directory and
+// then renames it over path, so that readers see either the old contents or
+// the new contents, never a partially written file.
+func WriteFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
+
+// MakeReadOnly removes write permission from path and everything within it.
+// Symbolic links are left alone.
+func MakeReadOnly(path string) error {
+	return filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return os.Chmod(path, info.Mode().Perm()&^0222)
+	})
+}
+
+// ForceRemoveAll removes path and everything within it, like os.RemoveAll,
+// after restoring write permission to any read-only directories, which would
+// otherwise prevent their contents from being removed
+func ForceRemoveAll(path string) error {
+	_ = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err == nil {
+			_ = os.Chmod(path, info.Mode().Perm()|0200)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
+// HashDirectory returns a SHA-256 hash of the relative paths and contents of
+// every file in the directory, skipping any files or directories with the
+// excluded names. The result is prefixed with "sha256-".
+func HashDirectory(root string, excludedNames ...string) (string, error) {
+	hash := sha256.New()
+	err := hashDirectoryInto(hash, root, "", excludedNames)
+	if err != nil {
+		return "", err
+	}
+
+	return "sha256-" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashDirectoryInto writes the relative path and hash of each file in dir to
+// hash. Symbolic links are followed, so that a directory of links hashes the
+// same as a copy of the files they link to.
+func hashDirectoryInto(hash io.Writer, dir string, relDir string, excludedNames []string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && slices.Contains(excludedNames, d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.Join(relDir, relPath)
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return err
+			}
+			info, err := os.Stat(target)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return hashDirectoryInto(hash, target, relPath, excludedNames)
+			}
+		} else if d.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, file)
+		if err != nil {
+			return err
+		}
+
+		// Use forward slashes so the hash is the same on every platform
+		fmt.Fprintf(hash, "%s\x00%x\n", filepath.ToSlash(relPath), fileHash.Sum(nil))
+		return nil
+	})
+}
+
 func RightPad(s string, char string, length int) string {
 	for len(s) < length {
 		s += char
 	}
 	return s
 }
+
+// KeyedMutex provides a separate lock for each key, so that work on different
+// keys can proceed concurrently while work on the same key is serialized.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewKeyedMutex() *KeyedMutex {
+	return &KeyedMutex{
+		locks: make(map[string]*sync.Mutex),
+	}
+}
+
+// Lock acquires the lock for the given key and returns a function which
+// releases it.
+func (km *KeyedMutex) Lock(key string) func() {
+	km.mu.Lock()
+	lock, ok := km.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		km.locks[key] = lock
+	}
+	km.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// StaleLockAge is how long a lock file may exist before it is assumed to
+// have been left behind by a process which crashed
+const StaleLockAge = 10 * time.Minute
+
+const lockRetryInterval = 100 * time.Millisecond
+
+// LockFile acquires a lock shared between processes by creating the file at
+// path, waiting for as long as another process holds it. It returns a
+// function which releases the lock.
+func LockFile(path string) (func(), error) {
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("Error creating lock file %s: %w", path, err)
+		}
+
+		info, err := os.Stat(path)
+		if err == nil && time.Since(info.ModTime()) > StaleLockAge {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
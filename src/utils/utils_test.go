@@ -2,7 +2,10 @@ package utils_test
 
 import (
 	. "ahkpm/src/utils"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,3 +29,152 @@ func TestIsSemverRange(t *testing.T) {
 	assert.True(t, IsSemVerRange("~1.2.3"))
 	assert.False(t, IsSemVerRange("foobar"))
 }
+
+func TestKeyedMutexSerializesSameKey(t *testing.T) {
+	km := NewKeyedMutex()
+	unlock := km.Lock("a")
+
+	acquired := make(chan bool)
+	go func() {
+		unlockAgain := km.Lock("a")
+		acquired <- true
+		unlockAgain()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lock for the same key was acquired twice")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	unlock()
+	assert.True(t, <-acquired)
+}
+
+func TestKeyedMutexAllowsDifferentKeys(t *testing.T) {
+	km := NewKeyedMutex()
+	unlockA := km.Lock("a")
+	unlockB := km.Lock("b")
+	unlockB()
+	unlockA()
+}
+
+func TestHashDirectory(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.ahk"), []byte("a"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "b.ahk"), []byte("b"), 0644))
+
+	hash, err := HashDirectory(dir, "ahkpm-modules")
+	assert.NoError(t, err)
+	assert.Regexp(t, "^sha256-[0-9a-f]{64}$", hash)
+
+	// Excluded directories do not affect the hash
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "ahkpm-modules"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ahkpm-modules", "c.ahk"), []byte("c"), 0644))
+	hashWithExcluded, err := HashDirectory(dir, "ahkpm-modules")
+	assert.NoError(t, err)
+	assert.Equal(t, hash, hashWithExcluded)
+
+	// Changing a file changes the hash
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "b.ahk"), []byte("changed"), 0644))
+	hashWithChange, err := HashDirectory(dir, "ahkpm-modules")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, hashWithChange)
+}
+
+func TestHashDirectoryFollowsSymlinks(t *testing.T) {
+	source := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(source, "a.ahk"), []byte("a"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(source, "lib"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(source, "lib", "b.ahk"), []byte("b"), 0644))
+
+	linked := t.TempDir()
+	if err := os.Symlink(filepath.Join(source, "a.ahk"), filepath.Join(linked, "a.ahk")); err != nil {
+		t.Skip("symbolic links are not supported: " + err.Error())
+	}
+	assert.NoError(t, os.Symlink(filepath.Join(source, "lib"), filepath.Join(linked, "lib")))
+
+	sourceHash, err := HashDirectory(source)
+	assert.NoError(t, err)
+	linkedHash, err := HashDirectory(linked)
+	assert.NoError(t, err)
+	assert.Equal(t, sourceHash, linkedHash)
+}
+
+func TestMakeReadOnlyAndForceRemoveAll(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "b.ahk"), []byte("b"), 0644))
+
+	assert.NoError(t, MakeReadOnly(dir))
+
+	for _, path := range []string{dir, filepath.Join(dir, "lib"), filepath.Join(dir, "lib", "b.ahk")} {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Zero(t, info.Mode().Perm()&0222, path)
+	}
+
+	assert.NoError(t, ForceRemoveAll(dir))
+	exists, err := FileExists(dir)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ahkpm.lock")
+	assert.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+	assert.NoError(t, WriteFileAtomic(path, []byte("new"), 0644))
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(contents))
+
+	// The temporary file is renamed over the original, so none are left behind
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	unlock, err := LockFile(path)
+	assert.Nil(t, err)
+
+	acquired := make(chan bool)
+	released := make(chan bool)
+	go func() {
+		unlockSecond, err := LockFile(path)
+		assert.Nil(t, err)
+		acquired <- true
+		unlockSecond()
+		released <- true
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lock was acquired while held")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	unlock()
+	<-acquired
+	<-released
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLockFileRemovesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	assert.Nil(t, os.WriteFile(path, []byte{}, 0644))
+	old := time.Now().Add(-StaleLockAge - time.Minute)
+	assert.Nil(t, os.Chtimes(path, old, old))
+
+	unlock, err := LockFile(path)
+	assert.Nil(t, err)
+	unlock()
+}